@@ -0,0 +1,212 @@
+package alerts
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recipient es a quién se notifica; cada canal usa el dato que necesita
+type Recipient struct {
+	IDUsuario int
+	Email     string
+	Phone     string
+}
+
+// Message es la notificación. Text puede traer <b>...</b>: Telegram lo
+// usa como HTML y los demás canales lo adaptan con Plain o Markdown.
+type Message struct {
+	Subject string
+	Text    string
+}
+
+// Plain devuelve el texto sin marcas, para email y SMS
+func (m Message) Plain() string {
+	return strings.NewReplacer("<b>", "", "</b>", "").Replace(m.Text)
+}
+
+// Markdown devuelve el texto con negritas de WhatsApp
+func (m Message) Markdown() string {
+	return strings.NewReplacer("<b>", "*", "</b>", "*").Replace(m.Text)
+}
+
+// Result es lo que pasó al enviar por un canal
+type Result struct {
+	Channel    string        `json:"canal"`
+	Delivered  bool          `json:"entregado"`
+	Skipped    bool          `json:"omitido,omitempty"` // el destinatario no tiene el dato que el canal necesita
+	ProviderID string        `json:"id_proveedor,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duracion"`
+}
+
+// Notifier es un canal de notificación (Telegram, email, SMS...)
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, to Recipient, msg Message) Result
+}
+
+// Registry envía por los canales registrados, en orden
+type Registry struct {
+	notifiers []Notifier
+}
+
+func NewRegistry(notifiers ...Notifier) *Registry {
+	return &Registry{notifiers: notifiers}
+}
+
+// Register agrega un canal al final
+func (r *Registry) Register(n Notifier) {
+	r.notifiers = append(r.notifiers, n)
+}
+
+// Names devuelve los canales en el orden en que se envían
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.notifiers))
+	for i, n := range r.notifiers {
+		names[i] = n.Name()
+	}
+	return names
+}
+
+// Send envía por cada canal en orden; un canal que falla no frena al resto
+func (r *Registry) Send(ctx context.Context, to Recipient, msg Message) []Result {
+	results := make([]Result, 0, len(r.notifiers))
+	for _, n := range r.notifiers {
+		start := time.Now()
+		res := n.Send(ctx, to, msg)
+		res.Channel = n.Name()
+		res.Duration = time.Since(start)
+		switch {
+		case res.Error != "":
+			log.Printf("❌ Error %s: %s", res.Channel, res.Error)
+		case res.Skipped:
+			log.Printf("⏭️ %s omitido para usuario %d", res.Channel, to.IDUsuario)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]func() Notifier{
+		"telegram": func() Notifier { return telegramNotifier{} },
+		"email":    func() Notifier { return emailNotifier{} },
+		"sms":      func() Notifier { return smsNotifier{} },
+		"whatsapp": func() Notifier { return whatsappNotifier{} },
+	}
+)
+
+// RegisterFactory agrega (o reemplaza) un canal que se puede nombrar en
+// RegistryFromEnv; hay que llamarlo antes de armar los registros
+func RegisterFactory(name string, factory func() Notifier) {
+	factoriesMu.Lock()
+	factories[name] = factory
+	factoriesMu.Unlock()
+}
+
+// RegistryFromEnv arma el registro con los canales de la variable (ej.
+// ALERT_CHANNELS=telegram,email), en ese orden. Sin la variable usa def;
+// con la variable vacía ("none") no se notifica por ningún canal.
+func RegistryFromEnv(name, def string) *Registry {
+	list, ok := os.LookupEnv(name)
+	if !ok {
+		list = def
+	}
+
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	r := NewRegistry()
+	for _, channel := range strings.Split(list, ",") {
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if channel == "" || channel == "none" {
+			continue
+		}
+		factory, ok := factories[channel]
+		if !ok {
+			known := make([]string, 0, len(factories))
+			for k := range factories {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			log.Printf("⚠️ %s: canal %q desconocido (disponibles: %s)", name, channel, strings.Join(known, ", "))
+			continue
+		}
+		r.Register(factory())
+	}
+	log.Printf("🔔 %s: %s", name, strings.Join(r.Names(), " → "))
+	return r
+}
+
+func failed(err error) Result {
+	return Result{Error: err.Error()}
+}
+
+type telegramNotifier struct{}
+
+func (telegramNotifier) Name() string { return "telegram" }
+
+// Sin teléfono igual se envía al chat por defecto (TELEGRAM_CHAT_ID)
+func (telegramNotifier) Send(ctx context.Context, to Recipient, msg Message) Result {
+	chatID, err := sendTelegramToUser(ctx, to.Phone, msg.Text)
+	if err != nil {
+		return failed(err)
+	}
+	return Result{Delivered: true, ProviderID: chatID}
+}
+
+type emailNotifier struct{}
+
+func (emailNotifier) Name() string { return "email" }
+
+func (emailNotifier) Send(ctx context.Context, to Recipient, msg Message) Result {
+	if to.Email == "" {
+		return Result{Skipped: true}
+	}
+	if err := ctx.Err(); err != nil {
+		return failed(err)
+	}
+	subject := msg.Subject
+	if subject == "" {
+		subject = "EasyGrow"
+	}
+	if err := SendEmailAlertTo(to.Email, subject, msg.Plain()); err != nil {
+		return failed(err)
+	}
+	return Result{Delivered: true}
+}
+
+type smsNotifier struct{}
+
+func (smsNotifier) Name() string { return "sms" }
+
+func (smsNotifier) Send(ctx context.Context, to Recipient, msg Message) Result {
+	if to.Phone == "" {
+		return Result{Skipped: true}
+	}
+	id, err := sendSMS(ctx, to.Phone, msg.Plain())
+	if err != nil {
+		return failed(err)
+	}
+	return Result{Delivered: true, ProviderID: id}
+}
+
+type whatsappNotifier struct{}
+
+func (whatsappNotifier) Name() string { return "whatsapp" }
+
+func (whatsappNotifier) Send(ctx context.Context, to Recipient, msg Message) Result {
+	if to.Phone == "" {
+		return Result{Skipped: true}
+	}
+	if err := sendWhatsApp(ctx, to.Phone, msg.Markdown()); err != nil {
+		return failed(err)
+	}
+	return Result{Delivered: true}
+}
@@ -2,6 +2,7 @@ package alerts
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -20,12 +21,18 @@ type VonageResponse struct {
 }
 
 func SendSMSAlert(to, message string) error {
+	_, err := sendSMS(context.Background(), to, message)
+	return err
+}
+
+// sendSMS devuelve el message-id de Vonage
+func sendSMS(ctx context.Context, to, message string) (string, error) {
 	apiKey := os.Getenv("VONAGE_API_KEY")
 	apiSecret := os.Getenv("VONAGE_API_SECRET")
 	from := os.Getenv("VONAGE_FROM_NUMBER")
 
 	if apiKey == "" || apiSecret == "" {
-		return fmt.Errorf("❌ Credenciales de Vonage no configuradas")
+		return "", fmt.Errorf("❌ Credenciales de Vonage no configuradas")
 	}
 
 	// API URL correcta para Vonage
@@ -42,13 +49,13 @@ func SendSMSAlert(to, message string) error {
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("❌ Error creando JSON: %w", err)
+		return "", fmt.Errorf("❌ Error creando JSON: %w", err)
 	}
 
 	// Crear request
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("❌ Error creando request: %w", err)
+		return "", fmt.Errorf("❌ Error creando request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -60,27 +67,27 @@ func SendSMSAlert(to, message string) error {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("❌ Error enviando request: %w", err)
+		return "", fmt.Errorf("❌ Error enviando request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Leer respuesta
 	var vonageResp VonageResponse
 	if err := json.NewDecoder(resp.Body).Decode(&vonageResp); err != nil {
-		return fmt.Errorf("❌ Error parseando respuesta: %w", err)
+		return "", fmt.Errorf("❌ Error parseando respuesta: %w", err)
 	}
 
 	// Verificar respuesta
 	if len(vonageResp.Messages) == 0 {
-		return fmt.Errorf("❌ No se recibió respuesta de Vonage")
+		return "", fmt.Errorf("❌ No se recibió respuesta de Vonage")
 	}
 
 	msg := vonageResp.Messages[0]
 	if msg.Status == "0" {
 		fmt.Printf("✅ SMS enviado exitosamente a: %s (ID: %s, Balance: %s)\n",
 			to, msg.MessageID, msg.RemainingBalance)
-		return nil
+		return msg.MessageID, nil
 	}
 
-	return fmt.Errorf("❌ Error Vonage: %s (Status: %s)", msg.ErrorText, msg.Status)
+	return "", fmt.Errorf("❌ Error Vonage: %s (Status: %s)", msg.ErrorText, msg.Status)
 }
@@ -2,6 +2,7 @@ package alerts
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -31,15 +32,21 @@ func SendTelegramAlert(message string) error {
 		return fmt.Errorf("❌ Credenciales de Telegram no configuradas")
 	}
 
-	return sendTelegramMessage(botToken, chatID, message)
+	return sendTelegramMessage(context.Background(), botToken, chatID, message)
 }
 
 // Enviar alerta personalizada a usuario específico por Telegram
 func SendTelegramAlertToUser(phone, message string) error {
+	_, err := sendTelegramToUser(context.Background(), phone, message)
+	return err
+}
+
+// sendTelegramToUser devuelve el Chat ID al que se envió
+func sendTelegramToUser(ctx context.Context, phone, message string) (string, error) {
 	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
 
 	if botToken == "" {
-		return fmt.Errorf("❌ Token de Telegram no configurado")
+		return "", fmt.Errorf("❌ Token de Telegram no configurado")
 	}
 
 	// Normalizar el número de teléfono
@@ -54,18 +61,18 @@ func SendTelegramAlertToUser(phone, message string) error {
 		// Si no encontramos el usuario específico, usar el chat ID por defecto
 		chatID = os.Getenv("TELEGRAM_CHAT_ID")
 		if chatID == "" {
-			return fmt.Errorf("❌ No se encontró Chat ID para %s y no hay chat por defecto", normalizedPhone)
+			return "", fmt.Errorf("❌ No se encontró Chat ID para %s y no hay chat por defecto", normalizedPhone)
 		}
 
 		// Incluir el número en el mensaje ya que va al chat grupal
 		message = fmt.Sprintf("📱 <b>Alerta para %s:</b>\n%s", normalizedPhone, message)
 	}
 
-	return sendTelegramMessage(botToken, chatID, message)
+	return chatID, sendTelegramMessage(ctx, botToken, chatID, message)
 }
 
 // Función auxiliar para enviar el mensaje a Telegram
-func sendTelegramMessage(botToken, chatID, message string) error {
+func sendTelegramMessage(ctx context.Context, botToken, chatID, message string) error {
 	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", botToken)
 
 	telegramMsg := TelegramMessage{
@@ -81,7 +88,7 @@ func sendTelegramMessage(botToken, chatID, message string) error {
 
 	client := &http.Client{Timeout: 30 * time.Second}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("❌ Error creando request: %w", err)
 	}
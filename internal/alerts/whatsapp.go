@@ -2,6 +2,7 @@ package alerts
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -16,6 +17,10 @@ type WhatsAppMessage struct {
 }
 
 func SendWhatsAppAlert(phone, message string) error {
+	return sendWhatsApp(context.Background(), phone, message)
+}
+
+func sendWhatsApp(ctx context.Context, phone, message string) error {
 	// Usar las variables correctas del .env
 	apiInstance := os.Getenv("GREEN_API_INSTANCE_ID")
 	apiToken := os.Getenv("GREEN_API_TOKEN")
@@ -47,7 +52,7 @@ func SendWhatsAppAlert(phone, message string) error {
 
 	client := &http.Client{Timeout: 30 * time.Second}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("❌ Error creando request: %w", err)
 	}
@@ -0,0 +1,110 @@
+package amqp
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"WEBSOCKER_EASYGROW/internal/websocket"
+
+	"github.com/streadway/amqp"
+)
+
+// hubActions ejecuta los comandos del WebSocket que tocan la BD o los dispositivos
+type hubActions struct {
+	db           *sql.DB
+	ch           *amqp.Channel
+	hub          *websocket.Hub
+	commandQueue string
+}
+
+func newHubActions(dbConn *sql.DB, ch *amqp.Channel, hub *websocket.Hub) *hubActions {
+	return &hubActions{db: dbConn, ch: ch, hub: hub, commandQueue: commandQueue()}
+}
+
+// commandQueue es la cola de la que los dispositivos leen los comandos de bomba
+func commandQueue() string {
+	if q := os.Getenv("BOMBA_COMMAND_QUEUE"); q != "" {
+		return q
+	}
+	return "comandos_bomba" // valor por defecto
+}
+
+// Un comando que el dispositivo no leyó en este tiempo ya no se ejecuta
+const commandTTL = time.Minute
+
+// Marcar una alerta abierta como reconocida por el usuario dueño de la
+// planta; reconocer una alerta ya reconocida o resuelta no hace nada
+func (a *hubActions) AckAlert(userID int, idAlerta int64) error {
+	ref := alertRef{id: idAlerta}
+	queryOwner := `
+		SELECT a.id_planta, a.tipo_alerta, COALESCE(a.mac_address, ''), COALESCE(a.nombre_sensor, ''), a.estado
+		FROM alertas a
+		JOIN planta p ON a.id_planta = p.id_planta
+		JOIN dispositivo d ON p.id_dispositivo = d.id_dispositivo
+		WHERE a.id_alerta = ? AND d.id_usuario = ?
+	`
+	err := a.db.QueryRow(queryOwner, idAlerta, userID).
+		Scan(&ref.plantaID, &ref.tipoAlerta, &ref.mac, &ref.nombre, &ref.estado)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("alerta %d: %w", idAlerta, websocket.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("error verificando alerta %d: %w", idAlerta, err)
+	}
+
+	updateQuery := `
+		UPDATE alertas
+		SET reconocida = 1, fecha_reconocida = NOW(), reconocida_por = ?, estado = ?
+		WHERE id_alerta = ? AND estado = ?
+	`
+	res, err := a.db.Exec(updateQuery, userID, estadoReconocida, idAlerta, estadoAbierta)
+	if err != nil {
+		return fmt.Errorf("error reconociendo alerta %d: %w", idAlerta, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		log.Printf("ℹ️ Alerta %d ya estaba %s", idAlerta, ref.estado)
+		return nil
+	}
+
+	log.Printf("✅ Alerta %d reconocida por usuario %d", idAlerta, userID)
+	publishTransition(a.hub, userID, ref, estadoReconocida, userID)
+	return nil
+}
+
+// Publicar la orden para la bomba en la cola de comandos del dispositivo
+func (a *hubActions) PumpAction(userID int, action websocket.PumpAction) error {
+	var exists bool
+	queryOwner := `SELECT EXISTS(SELECT 1 FROM dispositivo WHERE mac_address = ? AND id_usuario = ?)`
+	if err := a.db.QueryRow(queryOwner, action.MacAddress, userID).Scan(&exists); err != nil {
+		return fmt.Errorf("error verificando dispositivo %s: %w", action.MacAddress, err)
+	}
+	if !exists {
+		return fmt.Errorf("dispositivo %s: %w", action.MacAddress, websocket.ErrNotFound)
+	}
+
+	body, err := json.Marshal(struct {
+		websocket.PumpAction
+		IDUsuario int    `json:"id_usuario"`
+		Fecha     string `json:"fecha"`
+	}{action, userID, time.Now().Format("2006-01-02 15:04:05")})
+	if err != nil {
+		return err
+	}
+
+	err = a.ch.Publish("", a.commandQueue, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         body,
+	})
+	if err != nil {
+		return fmt.Errorf("error publicando comando de bomba: %w", err)
+	}
+
+	log.Printf("🚰 Comando bomba %s %s enviado a %s (usuario %d)",
+		action.Bomba, action.Accion, action.MacAddress, userID)
+	return nil
+}
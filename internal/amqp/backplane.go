@@ -0,0 +1,91 @@
+package amqp
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"WEBSOCKER_EASYGROW/internal/websocket"
+
+	"github.com/streadway/amqp"
+)
+
+// hubBackplane reparte los mensajes del Hub entre todas las instancias
+// usando un exchange fanout en la misma conexión de RabbitMQ. Cada
+// instancia consume de su propia cola exclusiva ligada al exchange.
+// Publish se llama desde varias goroutines sobre el mismo canal: la
+// librería ya serializa los envíos de un canal, como en settle y pump_action.
+type hubBackplane struct {
+	conn     *amqp.Connection
+	exchange string
+	ch       *amqp.Channel
+}
+
+func newHubBackplane(conn *amqp.Connection) (*hubBackplane, error) {
+	exchange := os.Getenv("HUB_EXCHANGE")
+	if exchange == "" {
+		exchange = "easygrow.hub" // valor por defecto
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo canal del backplane: %w", err)
+	}
+	if err := ch.ExchangeDeclare(exchange, "fanout", true, false, false, false, nil); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("error declarando exchange %s: %w", exchange, err)
+	}
+
+	return &hubBackplane{conn: conn, exchange: exchange, ch: ch}, nil
+}
+
+func (b *hubBackplane) Publish(msg websocket.Message) error {
+	body, err := websocket.EncodeMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	return b.ch.Publish(b.exchange, "", false, false, amqp.Publishing{
+		ContentType: "application/json",
+		Body:        body,
+	})
+}
+
+func (b *hubBackplane) Subscribe(deliver func(websocket.Message)) error {
+	ch, err := b.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("error abriendo canal del backplane: %w", err)
+	}
+
+	// Cola con nombre del servidor, exclusiva y auto-delete: desaparece con la instancia
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("error declarando cola del backplane: %w", err)
+	}
+	if err := ch.QueueBind(q.Name, "", b.exchange, false, nil); err != nil {
+		ch.Close()
+		return fmt.Errorf("error ligando cola del backplane: %w", err)
+	}
+	msgs, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("error consumiendo backplane: %w", err)
+	}
+
+	go func() {
+		defer ch.Close()
+		for d := range msgs {
+			msg, err := websocket.DecodeMessage(d.Body)
+			if err != nil {
+				log.Printf("❌ Mensaje de backplane inválido: %v", err)
+				continue
+			}
+			deliver(msg)
+		}
+		log.Println("🔌 Consumidor del backplane detenido")
+	}()
+
+	log.Printf("🔗 Backplane del hub en exchange %s (cola %s)", b.exchange, q.Name)
+	return nil
+}
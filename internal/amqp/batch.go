@@ -0,0 +1,178 @@
+package amqp
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"WEBSOCKER_EASYGROW/utils"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	defaultBatchSize = defaultPrefetch
+	defaultBatchWait = 200 * time.Millisecond
+)
+
+// lecturaRow es una fila de lectura_datos lista para insertar
+type lecturaRow struct {
+	valor    float64
+	sensorID int
+	plantaID sql.NullInt64
+	calidad  string
+	leida    time.Time // hora de la lectura según el dispositivo (o de recepción)
+	desfase  bool      // el reloj del dispositivo estaba fuera de rango
+}
+
+type pendingLectura struct {
+	row  lecturaRow
+	done func(error)
+}
+
+// lecturaBatcher junta lecturas hasta LECTURAS_BATCH_SIZE filas o
+// LECTURAS_BATCH_WAIT_MS milisegundos y las guarda con un único INSERT
+// multi-fila dentro de una transacción. Cada done se llama después del
+// commit (o del fallo), en el mismo orden en que llegaron las lecturas;
+// done no debe bloquear, porque frena el siguiente lote.
+// El lote no puede superar el prefetch de la cola: RabbitMQ no entrega más
+// mensajes hasta que se confirmen los pendientes, y el lote saldría siempre
+// por tiempo.
+type lecturaBatcher struct {
+	db   *sql.DB
+	size int
+	wait time.Duration
+	in   chan pendingLectura
+}
+
+func newLecturaBatcher(db *sql.DB, prefetch int) *lecturaBatcher {
+	b := &lecturaBatcher{
+		db:   db,
+		size: utils.EnvPositiveInt("LECTURAS_BATCH_SIZE", defaultBatchSize),
+		wait: time.Duration(utils.EnvPositiveInt("LECTURAS_BATCH_WAIT_MS", int(defaultBatchWait/time.Millisecond))) * time.Millisecond,
+	}
+	if b.size > prefetch {
+		log.Printf("⚠️ LECTURAS_BATCH_SIZE (%d) mayor que el prefetch de la cola, se usa %d", b.size, prefetch)
+		b.size = prefetch
+	}
+	b.in = make(chan pendingLectura, b.size)
+	log.Printf("🔧 Lecturas en lotes de hasta %d filas o %s", b.size, b.wait)
+	go b.run()
+	return b
+}
+
+// add encola la fila; done recibe nil cuando la transacción hizo commit
+func (b *lecturaBatcher) add(row lecturaRow, done func(error)) {
+	b.in <- pendingLectura{row: row, done: done}
+}
+
+func (b *lecturaBatcher) run() {
+	var batch []pendingLectura
+	timer := time.NewTimer(b.wait)
+	timer.Stop()
+
+	for {
+		select {
+		case p := <-b.in:
+			if len(batch) == 0 {
+				timer.Reset(b.wait)
+			}
+			batch = append(batch, p)
+			if len(batch) < b.size {
+				continue
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+		}
+		b.flush(batch)
+		batch = nil
+	}
+}
+
+func (b *lecturaBatcher) flush(batch []pendingLectura) {
+	if len(batch) == 0 {
+		return
+	}
+	start := time.Now()
+	if err := b.insert(batch); err != nil {
+		log.Printf("❌ Error insertando lote de %d lecturas: %v", len(batch), err)
+		b.flushEach(batch)
+		return
+	}
+	log.Printf("✅ Lote de %d lecturas insertado en %s", len(batch), time.Since(start).Round(time.Millisecond))
+
+	for _, p := range batch {
+		p.done(nil)
+	}
+}
+
+// flushEach reintenta las filas de un lote fallido de a una, para que una
+// fila que MySQL rechaza no arrastre al resto: esa va a dead-letter y las
+// demás se guardan igual. Si el error es de conexión vuelven a la cola.
+func (b *lecturaBatcher) flushEach(batch []pendingLectura) {
+	guardadas := 0
+	for _, p := range batch {
+		err := b.insert([]pendingLectura{p})
+		if err == nil {
+			guardadas++
+			p.done(nil)
+			continue
+		}
+		if rechazada(err) {
+			err = poison(motivoLecturaRechazada, err)
+		}
+		p.done(err)
+	}
+	log.Printf("🔁 Lote reintentado fila por fila: %d de %d lecturas guardadas", guardadas, len(batch))
+}
+
+// rechazada dice si MySQL rechazó la fila por sus datos. Cualquier otro
+// error (conexión, servidor apagándose, solo lectura durante un failover,
+// tabla o columna que falta porque no se migró) es transitorio: descartar
+// la lectura por eso la perdería para siempre.
+func rechazada(err error) bool {
+	var me *mysql.MySQLError
+	if !errors.As(err, &me) {
+		return false
+	}
+	switch me.Number {
+	case 1048, // columna no puede ser NULL
+		1062, // entrada duplicada
+		1264, // valor fuera de rango
+		1265, // dato truncado
+		1292, // fecha u hora incorrecta
+		1366, // valor incorrecto para la columna
+		1406, // dato demasiado largo
+		1452, // falla la clave foránea
+		3819: // falla un CHECK
+		return true
+	}
+	return false
+}
+
+func (b *lecturaBatcher) insert(batch []pendingLectura) error {
+	placeholders := make([]string, len(batch))
+	args := make([]interface{}, 0, len(batch)*6)
+	for i, p := range batch {
+		placeholders[i] = "(?, ?, ?, ?, ?, ?)"
+		args = append(args, p.row.valor, p.row.sensorID, p.row.plantaID, p.row.calidad,
+			p.row.leida.UTC(), p.row.desfase)
+	}
+	query := "INSERT INTO lectura_datos (valor, id_sensor, id_planta, calidad_dato, fecha_lectura, desfase_reloj) VALUES " +
+		strings.Join(placeholders, ", ")
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,131 @@
+package amqp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"WEBSOCKER_EASYGROW/internal/alerts"
+	"WEBSOCKER_EASYGROW/internal/websocket"
+)
+
+// bombaHandler procesa la cola de eventos de bomba
+type bombaHandler struct {
+	deps Deps
+}
+
+// bombaRecord acompaña el evento con la bomba detectada
+type bombaRecord struct {
+	BombaEvent
+	bombaDetectada string
+	fecha          time.Time // fecha del dispositivo o, si no sirve, de recepción
+	desfase        bool
+}
+
+func (r *bombaRecord) shardKey() string { return strings.ToUpper(r.MacAddress) }
+func (r *bombaRecord) idempotencyFields() (string, bool) {
+	if r.Fecha == "" {
+		return "", false
+	}
+	return fmt.Sprintf("%s|%s|%s|%s", strings.ToUpper(r.MacAddress), r.Evento, r.Bomba, r.Fecha), true
+}
+
+func newBombaHandler(deps Deps) *bombaHandler {
+	return &bombaHandler{deps: deps}
+}
+
+func (h *bombaHandler) Name() string { return "bomba event" }
+
+func (h *bombaHandler) Decode(body []byte) (interface{}, error) {
+	rec := &bombaRecord{}
+	if err := json.Unmarshal(body, &rec.BombaEvent); err != nil {
+		return nil, err
+	}
+	rec.fecha, rec.desfase = clock().readingTime(rec.MacAddress, rec.Fecha, time.Now())
+
+	// Extraer bomba del evento si no viene en el campo bomba
+	rec.bombaDetectada = rec.Bomba
+	if rec.bombaDetectada == "" {
+		eventoLower := strings.ToLower(rec.Evento)
+		if strings.Contains(eventoLower, "bomba a") {
+			rec.bombaDetectada = "A"
+		} else if strings.Contains(eventoLower, "bomba b") {
+			rec.bombaDetectada = "B"
+		}
+	}
+	return rec, nil
+}
+
+func (h *bombaHandler) Validate(r interface{}) error {
+	rec := r.(*bombaRecord)
+	if err := validateBombaEvent(rec.BombaEvent); err != nil {
+		return err
+	}
+
+	log.Printf("   🚰 EVENTO BOMBA: %s", rec.Evento)
+	log.Printf("      MAC: %s, Bomba: %s, Sensor ID: %d", rec.MacAddress, rec.bombaDetectada, rec.IDSensor)
+	if rec.ValorHumedad != 0 {
+		log.Printf("      Valor Humedad YL-69: %.0f ADC", rec.ValorHumedad)
+	}
+	if rec.TiempoEncendidaSeg != nil {
+		log.Printf("      Tiempo encendida: %d seg", *rec.TiempoEncendidaSeg)
+	}
+	return nil
+}
+
+func (h *bombaHandler) Persist(r interface{}) error {
+	rec := r.(*bombaRecord)
+	return insertBombaEvent(h.deps.DB, rec.BombaEvent, rec.fecha, rec.desfase)
+}
+
+// Enviar a WebSocket (solo al dueño del dispositivo, si está suscrito)
+func (h *bombaHandler) Broadcast(r interface{}) {
+	rec := r.(*bombaRecord)
+	ownerID, err := getUserIDByMac(h.deps.DB, rec.MacAddress)
+	if err != nil {
+		log.Printf("   ⚠️ No se envía a WebSocket: %v", err)
+		return
+	}
+	h.deps.Hub.Publish(websocket.Message{
+		Tipo:       websocket.TipoBomba,
+		MacAddress: rec.MacAddress,
+		IDUsuario:  ownerID,
+		Payload:    rec.BombaEvent,
+	})
+	log.Println("   📤 Enviado a WebSocket")
+}
+
+// Crear alerta informativa para eventos de bomba activada
+func (h *bombaHandler) Alert(r interface{}) {
+	rec := r.(*bombaRecord)
+	if !strings.Contains(strings.ToLower(rec.Evento), "activada") {
+		return
+	}
+	log.Printf("   💧 BOMBA ACTIVADA - Creando alerta informativa")
+
+	// Obtener usuario y enviar notificación
+	to, err := recipientByMac(h.deps.DB, rec.MacAddress)
+	if err != nil {
+		log.Printf("   ❌ Error obteniendo usuario: %v", err)
+		return
+	}
+	log.Printf("   👤 Usuario: %s, Tel: %s", to.Email, to.Phone)
+
+	alertMsg := fmt.Sprintf(`💧 <b>BOMBA ACTIVADA</b>
+📍 <b>Dispositivo:</b> %s
+🚰 <b>Bomba:</b> %s
+📊 <b>Sensor YL-69:</b> %.0f ADC (suelo seco)
+🕐 <b>Fecha:</b> %s
+
+💡 Tu sistema de riego está funcionando correctamente`,
+		rec.MacAddress, rec.bombaDetectada, rec.ValorHumedad,
+		rec.fecha.In(clock().loc).Format("2006-01-02 15:04:05"))
+
+	// Enviar por los canales de ALERT_CHANNELS_INFO (solo Telegram por defecto)
+	notify(h.deps.Notifiers.Info, to, alerts.Message{
+		Subject: "💧 Bomba activada - EasyGrow",
+		Text:    alertMsg,
+	})
+}
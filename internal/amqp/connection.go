@@ -0,0 +1,204 @@
+package amqp
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"WEBSOCKER_EASYGROW/internal/websocket"
+	"WEBSOCKER_EASYGROW/utils"
+
+	"github.com/streadway/amqp"
+)
+
+// Estados de la conexión con RabbitMQ que se reportan en /health
+const (
+	EstadoConectando   = "conectando"
+	EstadoConectado    = "conectado"
+	EstadoDesconectado = "desconectado"
+)
+
+const (
+	initialBackoff    = time.Second
+	defaultMaxBackoff = time.Minute
+	// Una sesión que duró más que esto reinicia el backoff
+	stableSession = 30 * time.Second
+)
+
+// ConnectionStatus es el estado de la conexión con RabbitMQ
+type ConnectionStatus struct {
+	Estado       string    `json:"estado"`
+	Desde        time.Time `json:"desde"`
+	UltimoError  string    `json:"ultimo_error,omitempty"`
+	Reconexiones int       `json:"reconexiones"`
+}
+
+var (
+	statusMu      sync.RWMutex
+	status        = ConnectionStatus{Estado: EstadoConectando, Desde: time.Now()}
+	everConnected bool
+)
+
+// Status devuelve el estado actual de la conexión para los health checks
+func Status() ConnectionStatus {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	return status
+}
+
+func setStatus(estado string, err error) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	if estado == EstadoConectado {
+		if everConnected {
+			status.Reconexiones++
+		}
+		everConnected = true
+	}
+	status.Estado = estado
+	status.Desde = time.Now()
+	if err != nil {
+		status.UltimoError = err.Error()
+	}
+}
+
+// consumerConfig es lo que necesita cada sesión con RabbitMQ
+type consumerConfig struct {
+	amqpURL  string
+	registry *Registry
+	topology *Topology
+}
+
+// supervise mantiene viva la sesión con RabbitMQ: cuando se cae la
+// conexión o algún canal, vuelve a conectar con backoff exponencial y
+// jitter, redeclara la topología y reinicia los consumidores.
+func supervise(cfg consumerConfig, dbConn *sql.DB, hub *websocket.Hub) {
+	maxBackoff := utils.EnvDuration("AMQP_RECONNECT_MAX", defaultMaxBackoff)
+
+	backoff := initialBackoff
+	for {
+		setStatus(EstadoConectando, nil)
+		started := time.Now()
+		err := runSession(cfg, dbConn, hub)
+		setStatus(EstadoDesconectado, err)
+
+		if time.Since(started) > stableSession {
+			backoff = initialBackoff
+		}
+		// Jitter: esperar entre la mitad y el total del backoff actual
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Printf("🔌 Sesión con RabbitMQ terminada: %v. Reintentando en %s", err, wait.Round(time.Millisecond))
+		time.Sleep(wait)
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// runSession conecta, declara la topología, arranca los consumidores y
+// bloquea hasta que se cierra la conexión o alguno de sus canales
+func runSession(cfg consumerConfig, dbConn *sql.DB, hub *websocket.Hub) error {
+	conn, err := amqp.Dial(cfg.amqpURL)
+	if err != nil {
+		return fmt.Errorf("no se pudo conectar a RabbitMQ: %w", err)
+	}
+	defer conn.Close()
+	log.Println("✅ Conexión a RabbitMQ OK")
+
+	// La librería cierra cada canal de NotifyClose, así que cada uno tiene
+	// el suyo y se reenvía a closed solo el primer cierre
+	closed := make(chan error, 1)
+	watch := func(notify chan *amqp.Error) {
+		go func() {
+			var err error = errors.New("conexión cerrada")
+			if amqpErr, ok := <-notify; ok && amqpErr != nil {
+				err = amqpErr
+			}
+			select {
+			case closed <- err:
+			default:
+			}
+		}()
+	}
+	watch(conn.NotifyClose(make(chan *amqp.Error, 1)))
+
+	// Crear canales separados para cada cola y para los comandos
+	openChannel := func(name string) (*amqp.Channel, error) {
+		ch, err := conn.Channel()
+		if err != nil {
+			return nil, fmt.Errorf("error abriendo canal para %s: %w", name, err)
+		}
+		watch(ch.NotifyClose(make(chan *amqp.Error, 1)))
+		return ch, nil
+	}
+	chSetup, err := openChannel("topología")
+	if err != nil {
+		return err
+	}
+	chComandos, err := openChannel("comandos")
+	if err != nil {
+		return err
+	}
+
+	// Exchanges, colas (incluido dead-letter) y bindings de la configuración
+	if err := cfg.topology.declare(chSetup); err != nil {
+		return err
+	}
+
+	// Repartir lo que publica el hub entre todas las instancias (HUB_BACKPLANE=amqp)
+	if os.Getenv("HUB_BACKPLANE") == "amqp" {
+		backplane, err := newHubBackplane(conn)
+		if err == nil {
+			err = hub.SetBackplane(backplane)
+		}
+		if err != nil {
+			return fmt.Errorf("error configurando backplane del hub: %w", err)
+		}
+	}
+
+	// Mensajes de administración para todas las instancias (invalidar caché)
+	chControl, err := openChannel("control")
+	if err != nil {
+		return err
+	}
+	if err := consumeControl(chControl); err != nil {
+		return err
+	}
+
+	// Canal para re-enviar mensajes de cuarentena desde /admin
+	chCuarentena, err := openChannel("cuarentena")
+	if err != nil {
+		return err
+	}
+	if q := quarantineMain.Load(); q != nil {
+		q.setChannel(chCuarentena)
+	}
+
+	// Canal para publicar los comandos que llegan por WebSocket
+	hub.SetActions(newHubActions(dbConn, chComandos, hub))
+
+	// Un canal y una goroutine por cola registrada
+	for _, queue := range cfg.registry.Queues() {
+		ch, err := openChannel(queue)
+		if err != nil {
+			return err
+		}
+		if err := consumeQueue(ch, queue, cfg.registry); err != nil {
+			return err
+		}
+	}
+
+	setStatus(EstadoConectado, nil)
+	log.Println("=" + strings.Repeat("=", 60))
+
+	// Esperar a que se caiga la conexión o cualquiera de los canales
+	return <-closed
+}
@@ -2,93 +2,64 @@ package amqp
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"WEBSOCKER_EASYGROW/internal/alerts"
 	"WEBSOCKER_EASYGROW/internal/db"
 	"WEBSOCKER_EASYGROW/internal/websocket"
-
-	"github.com/streadway/amqp"
 )
 
 // Estructuras para diferentes tipos de JSON
 type SensorData struct {
-	MacAddress string  `json:"mac_address"`
-	Valor      float64 `json:"valor"`
-	Nombre     string  `json:"nombre"`
-	Fecha      string  `json:"fecha"`
+	MacAddress string           `json:"mac_address"`
+	Valor      float64          `json:"valor"`
+	Nombre     string           `json:"nombre"`
+	Fecha      FechaDispositivo `json:"fecha"`
 }
 
 type BombaEvent struct {
-	MacAddress         string  `json:"mac_address"`
-	Evento             string  `json:"evento"`
-	Bomba              string  `json:"bomba,omitempty"`
-	IDSensor           int     `json:"id_sensor,omitempty"`
-	ValorHumedad       float64 `json:"valor_humedad,omitempty"`
-	TiempoEncendidaSeg *int    `json:"tiempo_encendida_seg"`
-	Fecha              string  `json:"fecha"`
+	MacAddress         string           `json:"mac_address"`
+	Evento             string           `json:"evento"`
+	Bomba              string           `json:"bomba,omitempty"`
+	IDSensor           int              `json:"id_sensor,omitempty"`
+	ValorHumedad       float64          `json:"valor_humedad,omitempty"`
+	TiempoEncendidaSeg *int             `json:"tiempo_encendida_seg"`
+	Fecha              FechaDispositivo `json:"fecha"`
 }
 
-// Función para insertar lecturas de sensores (mejorada)
-func insertSensorReading(dbConn *sql.DB, data SensorData) error {
-	// 1. Obtener el ID del sensor basado en MAC y nombre
-	var sensorID int
-	querySensor := `
-		SELECT s.id_sensor 
-		FROM sensor_datos s 
-		JOIN dispositivo d ON s.id_dispositivo = d.id_dispositivo 
-		WHERE d.mac_address = ? AND s.nombre_sensor = ? AND s.activo = 1
-	`
-
-	err := dbConn.QueryRow(querySensor, data.MacAddress, data.Nombre).Scan(&sensorID)
+// resolveSensorReading prepara la fila de lectura_datos: resuelve sensor y
+// planta y asigna la calidad. El INSERT lo hace el lecturaBatcher.
+func resolveSensorReading(dbConn *sql.DB, data SensorData, leida time.Time, desfase bool) (lecturaRow, error) {
+	// 1. Obtener el ID del sensor y 2. la planta asociada (si existe), desde la caché
+	sensorID, err := ids().sensorID(dbConn, data.MacAddress, data.Nombre)
 	if err != nil {
-		log.Printf("❌ Error obteniendo sensor para MAC %s, nombre %s: %v", data.MacAddress, data.Nombre, err)
-		return err
-	}
-
-	// 2. Obtener la planta asociada al dispositivo (si existe)
-	var plantaID sql.NullInt64
-	queryPlanta := `
-		SELECT p.id_planta 
-		FROM planta p 
-		JOIN dispositivo d ON p.id_dispositivo = d.id_dispositivo 
-		WHERE d.mac_address = ? AND p.activa = 1 
-		LIMIT 1
-	`
-	dbConn.QueryRow(queryPlanta, data.MacAddress).Scan(&plantaID)
-
-	// 3. Determinar calidad del dato
-	calidad := "bueno"
-	if isCritical(data.Nombre, data.Valor) {
-		calidad = "critico"
-	} else if isWarning(data.Nombre, data.Valor) {
-		calidad = "advertencia"
+		return lecturaRow{}, err
 	}
-
-	// 4. Insertar lectura
-	insertQuery := `
-		INSERT INTO lectura_datos (valor, id_sensor, id_planta, calidad_dato) 
-		VALUES (?, ?, ?, ?)
-	`
-
-	_, err = dbConn.Exec(insertQuery, data.Valor, sensorID, plantaID, calidad)
+	plantaID, err := ids().plantaID(dbConn, data.MacAddress)
 	if err != nil {
-		log.Printf("❌ Error insertando lectura: %v", err)
-		return err
+		return lecturaRow{}, err
 	}
 
-	log.Printf("✅ Lectura insertada: Sensor %d (%s), Valor %.2f, Calidad %s",
-		sensorID, data.Nombre, data.Valor, calidad)
-	return nil
+	// 3. Determinar calidad del dato con los umbrales de la planta/sensor
+	calidad := classifyReading(sensorID, plantaID, data.Nombre, data.Valor)
+
+	return lecturaRow{
+		valor:    data.Valor,
+		sensorID: sensorID,
+		plantaID: plantaID,
+		calidad:  calidad,
+		leida:    leida,
+		desfase:  desfase,
+	}, nil
 }
 
 // Función para insertar evento de bomba (corregida)
-func insertBombaEvent(dbConn *sql.DB, event BombaEvent) error {
+func insertBombaEvent(dbConn *sql.DB, event BombaEvent, fecha time.Time, desfase bool) error {
 	// 1. Verificar si el sensor existe antes de insertar
 	var sensorExists bool
 	if event.IDSensor != 0 {
@@ -121,8 +92,8 @@ func insertBombaEvent(dbConn *sql.DB, event BombaEvent) error {
 
 	// 3. Insertar el evento
 	insertQuery := `
-		INSERT INTO eventos_bomba (mac_address, evento, bomba, id_sensor, valor_humedad, tiempo_encendida_seg) 
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO eventos_bomba (mac_address, evento, bomba, id_sensor, valor_humedad, tiempo_encendida_seg, fecha_evento, desfase_reloj) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	var bomba sql.NullString
@@ -155,7 +126,9 @@ func insertBombaEvent(dbConn *sql.DB, event BombaEvent) error {
 		bomba,
 		idSensor,
 		valorHumedad,
-		tiempoEncendida)
+		tiempoEncendida,
+		fecha.UTC(),
+		desfase)
 
 	if err != nil {
 		log.Printf("❌ Error insertando evento bomba: %v", err)
@@ -167,24 +140,51 @@ func insertBombaEvent(dbConn *sql.DB, event BombaEvent) error {
 	return nil
 }
 
-func createAlert(dbConn *sql.DB, macAddress string, sensorName string, valor float64) {
+// createAlert inserta la alerta y la devuelve para publicarla; nil si no se creó
+func createAlert(dbConn *sql.DB, macAddress string, sensorName string, valor float64) *websocket.Alerta {
 	// Obtener planta asociada
-	var plantaID int
-	queryPlanta := `
-		SELECT p.id_planta 
-		FROM planta p 
-		JOIN dispositivo d ON p.id_dispositivo = d.id_dispositivo 
-		WHERE d.mac_address = ? AND p.activa = 1 
-		LIMIT 1
+	planta, err := ids().plantaID(dbConn, macAddress)
+	if err != nil || !planta.Valid {
+		log.Printf("⚠️ No se encontró planta activa para MAC %s", macAddress)
+		return nil
+	}
+	plantaID := int(planta.Int64)
+
+	tipoAlerta := tipoAlertaFor(sensorName)
+	mensaje := fmt.Sprintf("Valor crítico detectado: %.2f en %s (Dispositivo: %s)",
+		valor, sensorName, macAddress)
+
+	// repeticiones y fechas de repetición/recordatorio sirven para el cooldown
+	insertQuery := `
+		INSERT INTO alertas (id_planta, tipo_alerta, nivel, mensaje, mac_address, nombre_sensor,
+		                     repeticiones, fecha_ultima_repeticion, fecha_ultimo_recordatorio, estado) 
+		VALUES (?, ?, 'critico', ?, ?, ?, 1, NOW(), NOW(), ?)
 	`
 
-	err := dbConn.QueryRow(queryPlanta, macAddress).Scan(&plantaID)
+	res, err := dbConn.Exec(insertQuery, plantaID, tipoAlerta, mensaje, macAddress, sensorName, estadoAbierta)
 	if err != nil {
-		log.Printf("⚠️ No se encontró planta activa para MAC %s", macAddress)
-		return
+		log.Printf("❌ Error insertando alerta: %v", err)
+		return nil
+	}
+	log.Printf("✅ Alerta creada para planta %d: %s", plantaID, mensaje)
+
+	idAlerta, _ := res.LastInsertId()
+	return &websocket.Alerta{
+		IDAlerta:     idAlerta,
+		IDPlanta:     plantaID,
+		TipoAlerta:   tipoAlerta,
+		Nivel:        "critico",
+		Mensaje:      mensaje,
+		MacAddress:   macAddress,
+		Nombre:       sensorName,
+		Valor:        valor,
+		Repeticiones: 1,
+		Estado:       estadoAbierta,
 	}
+}
 
-	// Determinar tipo de alerta
+// Determinar tipo de alerta según el sensor
+func tipoAlertaFor(sensorName string) string {
 	tipoAlerta := "temperatura"
 	sensorLower := strings.ToLower(sensorName)
 	switch {
@@ -201,21 +201,7 @@ func createAlert(dbConn *sql.DB, macAddress string, sensorName string, valor flo
 	case strings.Contains(sensorLower, "yl-69") || (strings.Contains(sensorLower, "humedad") && strings.Contains(sensorLower, "suelo")):
 		tipoAlerta = "riego"
 	}
-
-	mensaje := fmt.Sprintf("Valor crítico detectado: %.2f en %s (Dispositivo: %s)",
-		valor, sensorName, macAddress)
-
-	insertQuery := `
-		INSERT INTO alertas (id_planta, tipo_alerta, nivel, mensaje) 
-		VALUES (?, ?, 'critico', ?)
-	`
-
-	_, err = dbConn.Exec(insertQuery, plantaID, tipoAlerta, mensaje)
-	if err != nil {
-		log.Printf("❌ Error insertando alerta: %v", err)
-	} else {
-		log.Printf("✅ Alerta creada para planta %d: %s", plantaID, mensaje)
-	}
+	return tipoAlerta
 }
 
 // Funciones auxiliares para detectar valores críticos (actualizadas)
@@ -259,178 +245,42 @@ func isWarning(sensor string, value float64) bool {
 	return false
 }
 
-func getUserByMac(db *sql.DB, mac string) (email, phone string, err error) {
+// Obtener el dueño del dispositivo con los datos de contacto para notificarlo
+func recipientByMac(db *sql.DB, mac string) (alerts.Recipient, error) {
 	query := `
-		SELECT u.correo, u.telefono
+		SELECT u.id_usuario, u.correo, u.telefono
 		FROM usuarios u
 		JOIN dispositivo d ON d.id_usuario = u.id_usuario
 		WHERE d.mac_address = ?
 	`
 
-	err = db.QueryRow(query, mac).Scan(&email, &phone)
+	var to alerts.Recipient
+	err := db.QueryRow(query, mac).Scan(&to.IDUsuario, &to.Email, &to.Phone)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return "", "", fmt.Errorf("no se encontró usuario para MAC %s", mac)
+			return to, fmt.Errorf("no se encontró usuario para MAC %s", mac)
 		}
-		return "", "", fmt.Errorf("error en consulta SQL: %w", err)
+		return to, fmt.Errorf("error en consulta SQL: %w", err)
 	}
 
-	return email, phone, nil
+	return to, nil
 }
 
-// Consumer para la cola de datos de sensores
-func consumeSensorData(ch *amqp.Channel, queueName string, dbConn *sql.DB, hub *websocket.Hub) {
-	msgs, err := ch.Consume(queueName, "", true, false, false, false, nil)
+// Obtener el dueño (id_usuario) del dispositivo para enrutar el WebSocket
+func getUserIDByMac(db *sql.DB, mac string) (int, error) {
+	var userID int
+	err := db.QueryRow(`SELECT id_usuario FROM dispositivo WHERE mac_address = ?`, mac).Scan(&userID)
 	if err != nil {
-		log.Fatalf("❌ Error al consumir cola %s: %v", queueName, err)
-	}
-
-	log.Printf("🔄 Consumiendo mensajes de cola: %s", queueName)
-
-	for msg := range msgs {
-		log.Println("📥 SENSOR DATA RECIBIDO:")
-		log.Printf("   📋 Raw Data: %s", string(msg.Body))
-		log.Printf("   🕐 Timestamp: %s", time.Now().Format("2006-01-02 15:04:05"))
-
-		// Enviar a WebSocket
-		hub.Broadcast(msg.Body)
-		log.Println("   📤 Enviado a WebSocket")
-
-		// Procesar datos del sensor
-		var sensorData SensorData
-		if err := json.Unmarshal(msg.Body, &sensorData); err != nil {
-			log.Printf("   ❌ Error parseando sensor data: %v", err)
-			continue
-		}
-
-		log.Printf("   📊 SENSOR DATA: %s = %.2f", sensorData.Nombre, sensorData.Valor)
-		log.Printf("      MAC: %s", sensorData.MacAddress)
-
-		// Insertar en BD
-		if err := insertSensorReading(dbConn, sensorData); err != nil {
-			log.Printf("   ❌ Error insertando sensor data: %v", err)
-		}
-
-		// Verificar si es crítico y crear alerta
-		if isCritical(sensorData.Nombre, sensorData.Valor) {
-			log.Printf("   🚨 VALOR CRÍTICO DETECTADO")
-
-			// Crear alerta en BD
-			createAlert(dbConn, sensorData.MacAddress, sensorData.Nombre, sensorData.Valor)
-
-			// Obtener usuario y enviar notificaciones
-			email, phone, err := getUserByMac(dbConn, sensorData.MacAddress)
-			if err != nil {
-				log.Printf("   ❌ Error obteniendo usuario: %v", err)
-			} else {
-				log.Printf("   👤 Usuario: %s, Tel: %s", email, phone)
-
-				alertMsg := fmt.Sprintf(`🚨 <b>ALERTA CRÍTICA - SENSOR</b>
-📍 <b>Dispositivo:</b> %s
-📊 <b>Sensor:</b> %s
-⚠️ <b>Valor:</b> %.2f
-🕐 <b>Fecha:</b> %s
-
-🔧 Revisa tu sistema EasyGrow inmediatamente`,
-					sensorData.MacAddress, sensorData.Nombre, sensorData.Valor,
-					time.Now().Format("2006-01-02 15:04:05"))
-
-				// Enviar alertas
-				go sendAllAlerts(email, phone, alertMsg)
-			}
-		}
-
-		log.Println("   " + strings.Repeat("-", 58))
-	}
-}
-
-// Consumer para la cola de eventos de bomba (corregido)
-func consumeBombaEvents(ch *amqp.Channel, queueName string, dbConn *sql.DB, hub *websocket.Hub) {
-	msgs, err := ch.Consume(queueName, "", true, false, false, false, nil)
-	if err != nil {
-		log.Fatalf("❌ Error al consumir cola %s: %v", queueName, err)
-	}
-
-	log.Printf("🔄 Consumiendo mensajes de cola: %s", queueName)
-
-	for msg := range msgs {
-		log.Println("📥 BOMBA EVENT RECIBIDO:")
-		log.Printf("   📋 Raw Data: %s", string(msg.Body))
-		log.Printf("   🕐 Timestamp: %s", time.Now().Format("2006-01-02 15:04:05"))
-
-		// Enviar a WebSocket
-		hub.Broadcast(msg.Body)
-		log.Println("   📤 Enviado a WebSocket")
-
-		// Procesar evento de bomba
-		var bombaEvent BombaEvent
-		if err := json.Unmarshal(msg.Body, &bombaEvent); err != nil {
-			log.Printf("   ❌ Error parseando evento bomba: %v", err)
-			continue
-		}
-
-		// Extraer bomba del evento si no viene en el campo bomba
-		bombaDetectada := bombaEvent.Bomba
-		if bombaDetectada == "" {
-			eventoLower := strings.ToLower(bombaEvent.Evento)
-			if strings.Contains(eventoLower, "bomba a") {
-				bombaDetectada = "A"
-			} else if strings.Contains(eventoLower, "bomba b") {
-				bombaDetectada = "B"
-			}
-		}
-
-		log.Printf("   🚰 EVENTO BOMBA: %s", bombaEvent.Evento)
-		log.Printf("      MAC: %s, Bomba: %s, Sensor ID: %d", bombaEvent.MacAddress, bombaDetectada, bombaEvent.IDSensor)
-		if bombaEvent.ValorHumedad != 0 {
-			log.Printf("      Valor Humedad YL-69: %.0f ADC", bombaEvent.ValorHumedad)
-		}
-		if bombaEvent.TiempoEncendidaSeg != nil {
-			log.Printf("      Tiempo encendida: %d seg", *bombaEvent.TiempoEncendidaSeg)
-		}
-
-		// Insertar en BD
-		if err := insertBombaEvent(dbConn, bombaEvent); err != nil {
-			log.Printf("   ❌ Error insertando evento bomba: %v", err)
-		}
-
-		// Crear alerta informativa para eventos de bomba activada
-		if strings.Contains(strings.ToLower(bombaEvent.Evento), "activada") {
-			log.Printf("   💧 BOMBA ACTIVADA - Creando alerta informativa")
-
-			// Obtener usuario y enviar notificación
-			email, phone, err := getUserByMac(dbConn, bombaEvent.MacAddress)
-			if err != nil {
-				log.Printf("   ❌ Error obteniendo usuario: %v", err)
-			} else {
-				log.Printf("   👤 Usuario: %s, Tel: %s", email, phone)
-
-				alertMsg := fmt.Sprintf(`💧 <b>BOMBA ACTIVADA</b>
-📍 <b>Dispositivo:</b> %s
-🚰 <b>Bomba:</b> %s
-📊 <b>Sensor YL-69:</b> %.0f ADC (suelo seco)
-🕐 <b>Fecha:</b> %s
-
-💡 Tu sistema de riego está funcionando correctamente`,
-					bombaEvent.MacAddress, bombaDetectada, bombaEvent.ValorHumedad,
-					time.Now().Format("2006-01-02 15:04:05"))
-
-				// Enviar solo notificación por Telegram (menos invasivo)
-				go func() {
-					if err := alerts.SendTelegramAlertToUser(phone, alertMsg); err != nil {
-						log.Printf("❌ Error Telegram: %v", err)
-					}
-				}()
-			}
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("no se encontró dispositivo para MAC %s", mac)
 		}
-
-		log.Println("   " + strings.Repeat("-", 58))
+		return 0, fmt.Errorf("error en consulta SQL: %w", err)
 	}
+	return userID, nil
 }
 
-// Función principal del consumer - maneja dos colas
-func ConsumeFromQueues(hub *websocket.Hub) {
-	amqpURL := os.Getenv("AMQP_URL")
+// Nombres de las colas a consumir, de SENSOR_QUEUE_NAME y BOMBA_QUEUE_NAME
+func queueNames() []string {
 	sensorQueue := os.Getenv("SENSOR_QUEUE_NAME") // datos_sensores
 	bombaQueue := os.Getenv("BOMBA_QUEUE_NAME")   // eventos_bomba
 
@@ -441,86 +291,83 @@ func ConsumeFromQueues(hub *websocket.Hub) {
 	if bombaQueue == "" {
 		bombaQueue = "eventos_bomba" // valor por defecto
 	}
+	return []string{sensorQueue, bombaQueue}
+}
 
-	conn, err := amqp.Dial(amqpURL)
-	if err != nil {
-		log.Fatalf("❌ No se pudo conectar a RabbitMQ: %v", err)
+// handlerRoute asocia una cola (y opcionalmente una routing key) con el
+// constructor de su handler
+type handlerRoute struct {
+	queue      string
+	routingKey string
+	build      func(deps Deps) Handler
+}
+
+// handlerRoutes son las colas que atiende el consumidor. Para atender una
+// cola nueva basta con implementar Handler y agregarla aquí.
+func handlerRoutes() []handlerRoute {
+	queues := queueNames()
+	return []handlerRoute{
+		{queue: queues[0], build: func(deps Deps) Handler { return newSensorHandler(deps, queues[0]) }},
+		{queue: queues[1], build: func(deps Deps) Handler { return newBombaHandler(deps) }},
 	}
-	defer conn.Close()
-	log.Println("✅ Conexión a RabbitMQ OK")
+}
 
-	// Crear canales separados para cada cola
-	chSensor, err := conn.Channel()
-	if err != nil {
-		log.Fatalf("❌ Error abriendo canal para sensores: %v", err)
+func registerHandlers(registry *Registry, deps Deps) {
+	for _, r := range handlerRoutes() {
+		registry.RegisterRoutingKey(r.queue, r.routingKey, r.build(deps))
 	}
-	defer chSensor.Close()
+}
 
-	chBomba, err := conn.Channel()
-	if err != nil {
-		log.Fatalf("❌ Error abriendo canal para bombas: %v", err)
+// Queues devuelve, ordenadas, las colas que atiende el consumidor; las
+// mismas que Registry.Queues sin tener que crear los handlers
+func Queues() []string {
+	seen := map[string]bool{}
+	var queues []string
+	for _, r := range handlerRoutes() {
+		if !seen[r.queue] {
+			seen[r.queue] = true
+			queues = append(queues, r.queue)
+		}
 	}
-	defer chBomba.Close()
+	sort.Strings(queues)
+	return queues
+}
 
-	// Conectar a la base de datos
+// Función principal del consumer - maneja todas las colas registradas
+func ConsumeFromQueues(hub *websocket.Hub, notifiers Notifiers) {
+	// Conectar a la base de datos (database/sql reconecta por su cuenta)
 	dbConn, err := db.ConnectDB()
 	if err != nil {
 		log.Fatalf("❌ BD error: %v", err)
 	}
 	defer dbConn.Close()
 
-	log.Println("🔄 Iniciando consumidores para ambas colas...")
-	log.Printf("   📊 Cola sensores: %s", sensorQueue)
-	log.Printf("   🚰 Cola bombas: %s", bombaQueue)
-	log.Println("=" + strings.Repeat("=", 60))
+	registry := NewRegistry()
+	registerHandlers(registry, Deps{DB: dbConn, Hub: hub, Notifiers: notifiers})
+	newQuarantineStore(dbConn)
+	newThresholdStore(dbConn)
 
-	// Lanzar goroutines para consumir de ambas colas simultáneamente
-	go consumeSensorData(chSensor, sensorQueue, dbConn, hub)
-	go consumeBombaEvents(chBomba, bombaQueue, dbConn, hub)
-
-	// Mantener el programa corriendo
-	select {}
-}
-
-// Función de compatibilidad - mantener para no romper el main.go existente
-func ConsumeFromQueue(hub *websocket.Hub) {
-	ConsumeFromQueues(hub)
-}
-
-func sendAllAlerts(email, phone, message string) {
-	// Telegram (más confiable y gratuito)
-	if err := alerts.SendTelegramAlertToUser(phone, message); err != nil {
-		log.Printf("❌ Error Telegram: %v", err)
+	topology, err := loadTopology(registry.Queues()...)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
 	}
 
-	// Email
-	if email != "" {
-		emailSubject := "🚨 ALERTA CRÍTICA - EasyGrow"
-		emailBody := strings.ReplaceAll(message, "<b>", "")
-		emailBody = strings.ReplaceAll(emailBody, "</b>", "")
-
-		if err := alerts.SendEmailAlertTo(email, emailSubject, emailBody); err != nil {
-			log.Printf("❌ Error Email: %v", err)
-		}
+	cfg := consumerConfig{
+		amqpURL:  os.Getenv("AMQP_URL"),
+		registry: registry,
+		topology: topology,
 	}
 
-	// SMS (solo para alertas críticas)
-	if phone != "" {
-		smsMsg := strings.ReplaceAll(message, "<b>", "")
-		smsMsg = strings.ReplaceAll(smsMsg, "</b>", "")
-
-		if err := alerts.SendSMSAlert(phone, smsMsg); err != nil {
-			log.Printf("❌ Error SMS: %v", err)
-		}
+	log.Println("🔄 Iniciando consumidores...")
+	for _, queue := range registry.Queues() {
+		log.Printf("   📬 Cola: %s", queue)
 	}
 
-	// WhatsApp
-	if phone != "" {
-		waMsg := strings.ReplaceAll(message, "<b>", "*")
-		waMsg = strings.ReplaceAll(waMsg, "</b>", "*")
+	// Conectar y reconectar a RabbitMQ indefinidamente
+	supervise(cfg, dbConn, hub)
+}
 
-		if err := alerts.SendWhatsAppAlert(phone, waMsg); err != nil {
-			log.Printf("❌ Error WhatsApp: %v", err)
-		}
-	}
+// Función de compatibilidad - mantener para no romper el main.go existente
+func ConsumeFromQueue(hub *websocket.Hub) {
+	ConsumeFromQueues(hub, NotifiersFromEnv())
 }
@@ -0,0 +1,75 @@
+package amqp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/streadway/amqp"
+)
+
+// Acciones que se aceptan por el exchange de control
+const (
+	accionInvalidarCache   = "invalidar_cache"
+	accionRecargarUmbrales = "recargar_umbrales"
+)
+
+// controlMessage es un mensaje de administración para todas las instancias,
+// ej. {"accion": "invalidar_cache", "mac_address": "AA:BB:CC:DD:EE:FF"}.
+// Sin mac_address se vacía la caché completa.
+type controlMessage struct {
+	Accion     string `json:"accion"`
+	MacAddress string `json:"mac_address,omitempty"`
+}
+
+func controlExchange() string {
+	if ex := os.Getenv("CONTROL_EXCHANGE"); ex != "" {
+		return ex
+	}
+	return "easygrow.control" // valor por defecto
+}
+
+// consumeControl liga una cola exclusiva de esta instancia al exchange
+// fanout de control, así un solo mensaje llega a todas las instancias
+func consumeControl(ch *amqp.Channel) error {
+	exchange := controlExchange()
+	if err := ch.ExchangeDeclare(exchange, "fanout", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("error declarando exchange %s: %w", exchange, err)
+	}
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return fmt.Errorf("error declarando cola de control: %w", err)
+	}
+	if err := ch.QueueBind(q.Name, "", exchange, false, nil); err != nil {
+		return fmt.Errorf("error ligando cola de control: %w", err)
+	}
+	msgs, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("error consumiendo cola de control: %w", err)
+	}
+
+	go func() {
+		for d := range msgs {
+			var msg controlMessage
+			if err := json.Unmarshal(d.Body, &msg); err != nil {
+				log.Printf("❌ Mensaje de control inválido: %v", err)
+				continue
+			}
+			switch msg.Accion {
+			case accionInvalidarCache:
+				InvalidateIDCache(msg.MacAddress)
+			case accionRecargarUmbrales:
+				if err := ReloadThresholds(); err != nil {
+					log.Printf("❌ Error recargando umbrales: %v", err)
+				}
+			default:
+				log.Printf("⚠️ Acción de control desconocida: %q", msg.Accion)
+			}
+		}
+		log.Println("🔌 Consumidor de control detenido")
+	}()
+
+	log.Printf("🎛️ Escuchando mensajes de control en exchange %s", exchange)
+	return nil
+}
@@ -0,0 +1,94 @@
+package amqp
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"WEBSOCKER_EASYGROW/utils"
+)
+
+const (
+	defaultAlertCooldown = 15 * time.Minute
+	defaultAlertReminder = time.Hour
+)
+
+// alertWindow controla cuándo una lectura crítica abre una alerta nueva.
+// Mientras sigan llegando lecturas críticas del mismo dispositivo, sensor
+// y tipo de alerta con menos de ALERT_COOLDOWN entre una y otra, se
+// cuentan sobre la alerta abierta en vez de crear otra; cada
+// ALERT_REMINDER_INTERVAL se manda un recordatorio de que sigue crítico.
+type alertWindow struct {
+	cooldown time.Duration
+	reminder time.Duration
+}
+
+func alertWindowFromEnv() alertWindow {
+	return alertWindow{
+		cooldown: utils.EnvDuration("ALERT_COOLDOWN", defaultAlertCooldown),
+		reminder: utils.EnvDuration("ALERT_REMINDER_INTERVAL", defaultAlertReminder),
+	}
+}
+
+// openAlert es la alerta que sigue dentro del cooldown
+type openAlert struct {
+	id           int64
+	plantaID     int
+	repeticiones int
+	recordar     bool // ya pasó ALERT_REMINDER_INTERVAL desde el último aviso
+}
+
+// findOpen busca la alerta sin resolver del mismo dispositivo, sensor y tipo cuya última
+// repetición esté dentro del cooldown. Las fechas se comparan en MySQL
+// para no depender de la zona horaria del servidor.
+func (w alertWindow) findOpen(dbConn *sql.DB, mac, sensor, tipo string) (*openAlert, error) {
+	var a openAlert
+	var desdeAviso int64
+	var estado string
+	err := dbConn.QueryRow(`
+		SELECT id_alerta, id_planta, repeticiones, estado,
+		       TIMESTAMPDIFF(SECOND, fecha_ultimo_recordatorio, NOW())
+		FROM alertas
+		WHERE mac_address = ? AND nombre_sensor = ? AND tipo_alerta = ?
+		  AND estado <> ?
+		  AND fecha_ultima_repeticion >= NOW() - INTERVAL ? SECOND
+		ORDER BY id_alerta DESC
+		LIMIT 1
+	`, mac, sensor, tipo, estadoResuelta, int64(w.cooldown/time.Second)).
+		Scan(&a.id, &a.plantaID, &a.repeticiones, &estado, &desdeAviso)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Si el usuario ya la reconoció no se le recuerda
+	a.recordar = estado == estadoAbierta && time.Duration(desdeAviso)*time.Second >= w.reminder
+	return &a, nil
+}
+
+// repeat cuenta la lectura sobre la alerta abierta y, si toca recordatorio,
+// lo registra en la misma sentencia
+func (w alertWindow) repeat(dbConn *sql.DB, a *openAlert) error {
+	a.repeticiones++
+	query := `UPDATE alertas SET repeticiones = repeticiones + 1, fecha_ultima_repeticion = NOW() WHERE id_alerta = ?`
+	if a.recordar {
+		query = `UPDATE alertas SET repeticiones = repeticiones + 1, fecha_ultima_repeticion = NOW(),
+		         fecha_ultimo_recordatorio = NOW() WHERE id_alerta = ?`
+	}
+	if _, err := dbConn.Exec(query, a.id); err != nil {
+		return err
+	}
+	log.Printf("   🔕 Alerta %d sigue abierta (%d lecturas críticas)", a.id, a.repeticiones)
+	return nil
+}
+
+// keepOpen renueva el cooldown de la alerta sin resolver sin contar la
+// lectura, para que no se abra otra si el valor vuelve a empeorar
+func (w alertWindow) keepOpen(dbConn *sql.DB, mac, sensor, tipo string) error {
+	_, err := dbConn.Exec(`
+		UPDATE alertas SET fecha_ultima_repeticion = NOW()
+		WHERE mac_address = ? AND nombre_sensor = ? AND tipo_alerta = ? AND estado <> ?
+	`, mac, sensor, tipo, estadoResuelta)
+	return err
+}
@@ -0,0 +1,96 @@
+package amqp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Motivos con los que un mensaje termina en la cola de dead-letter
+const (
+	motivoJSONInvalido      = "json_invalido"
+	motivoSensorDesconocido = "sensor_desconocido"
+	motivoLecturaRechazada  = "lectura_rechazada"
+)
+
+// Espera antes de devolver a la cola un mensaje con error transitorio,
+// para no girar en vacío mientras la BD está caída
+const requeueDelay = time.Second
+
+// poisonError marca un mensaje que nunca se va a poder procesar: no se
+// reintenta, se manda al exchange de dead-letter con el motivo
+type poisonError struct {
+	reason string
+	err    error
+}
+
+func (e *poisonError) Error() string {
+	return fmt.Sprintf("%s: %v", e.reason, e.err)
+}
+
+func (e *poisonError) Unwrap() error {
+	return e.err
+}
+
+func poison(reason string, err error) error {
+	return &poisonError{reason: reason, err: err}
+}
+
+func deadLetterExchange() string {
+	if ex := os.Getenv("DLX_EXCHANGE"); ex != "" {
+		return ex
+	}
+	return "easygrow.dlx" // valor por defecto
+}
+
+// settle confirma el mensaje según el resultado de procesarlo:
+// ack si se guardó, dead-letter si es veneno, nack con requeue si el error es transitorio
+func settle(ch *amqp.Channel, queueName string, msg amqp.Delivery, err error) {
+	if err == nil {
+		if ackErr := msg.Ack(false); ackErr != nil {
+			log.Printf("   ❌ Error confirmando mensaje: %v", ackErr)
+		}
+		return
+	}
+
+	var pe *poisonError
+	if errors.As(err, &pe) {
+		if dlErr := publishDeadLetter(ch, queueName, msg, pe.reason, err); dlErr != nil {
+			log.Printf("   ❌ No se pudo mandar a dead-letter, se reintenta: %v", dlErr)
+			time.Sleep(requeueDelay)
+			msg.Nack(false, true)
+			return
+		}
+		log.Printf("   ☠️ Mensaje enviado a dead-letter (%s): %v", pe.reason, err)
+		msg.Ack(false)
+		return
+	}
+
+	log.Printf("   🔁 Error transitorio, devolviendo mensaje a la cola: %v", err)
+	time.Sleep(requeueDelay)
+	msg.Nack(false, true)
+}
+
+func publishDeadLetter(ch *amqp.Channel, queueName string, msg amqp.Delivery, reason string, cause error) error {
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers["x-failure-reason"] = reason
+	headers["x-failure-detail"] = cause.Error()
+	headers["x-original-queue"] = queueName
+	headers["x-failed-at"] = time.Now().Format(time.RFC3339)
+
+	return ch.Publish(deadLetterExchange(), queueName, false, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    msg.MessageId,
+		Timestamp:    msg.Timestamp,
+		Body:         msg.Body,
+	})
+}
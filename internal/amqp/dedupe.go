@@ -0,0 +1,125 @@
+package amqp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"WEBSOCKER_EASYGROW/utils"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	defaultDedupeWindow = 10000
+	defaultDedupeTTL    = 10 * time.Minute
+)
+
+// idempotencyKeyer lo implementan los registros que saben qué campos
+// identifican un mismo evento cuando el firmware reintenta el publish.
+// Devuelve false si el registro no trae con qué distinguir dos eventos
+// iguales (por ejemplo, sin fecha del dispositivo).
+type idempotencyKeyer interface {
+	idempotencyFields() (string, bool)
+}
+
+// idempotencyKey usa el message-id si el publicador lo puso y, si no, un
+// hash de los campos del registro (o del cuerpo completo). Devuelve "" si
+// el mensaje no se puede deduplicar.
+func idempotencyKey(queueName string, msg amqp.Delivery, rec interface{}) string {
+	if msg.MessageId != "" {
+		return queueName + "|id|" + msg.MessageId
+	}
+	data := msg.Body
+	if k, ok := rec.(idempotencyKeyer); ok {
+		fields, ok := k.idempotencyFields()
+		if !ok {
+			return ""
+		}
+		data = []byte(fields)
+	}
+	sum := sha256.Sum256(data)
+	return queueName + "|hash|" + hex.EncodeToString(sum[:16])
+}
+
+type dedupeEntry struct {
+	at   time.Time
+	slot int
+}
+
+// dedupeWindow recuerda las últimas DEDUPE_WINDOW claves durante a lo
+// sumo DEDUPE_TTL. Una clave se reserva al despachar el mensaje y se
+// libera si el procesamiento falla, así la reentrega del original no se
+// descarta como duplicado de sí misma.
+type dedupeWindow struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	seen  map[string]dedupeEntry
+	order []string // anillo con las claves en orden de llegada
+	next  int
+
+	duplicados atomic.Int64
+}
+
+var (
+	dedupeOnce sync.Once
+	dedupeMain *dedupeWindow
+)
+
+func dedupe() *dedupeWindow {
+	dedupeOnce.Do(func() {
+		dedupeMain = newDedupeWindow(
+			utils.EnvPositiveInt("DEDUPE_WINDOW", defaultDedupeWindow),
+			utils.EnvDuration("DEDUPE_TTL", defaultDedupeTTL))
+	})
+	return dedupeMain
+}
+
+func newDedupeWindow(size int, ttl time.Duration) *dedupeWindow {
+	return &dedupeWindow{
+		ttl:   ttl,
+		seen:  make(map[string]dedupeEntry, size),
+		order: make([]string, size),
+	}
+}
+
+// reserve devuelve false si la clave ya se vio dentro de la ventana
+func (d *dedupeWindow) reserve(key string) bool {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.seen[key]; ok && now.Sub(e.at) < d.ttl {
+		d.duplicados.Add(1)
+		return false
+	}
+
+	// Pisar la clave más vieja del anillo, si sigue siendo la de ese slot
+	if old := d.order[d.next]; old != "" {
+		if e, ok := d.seen[old]; ok && e.slot == d.next {
+			delete(d.seen, old)
+		}
+	}
+	d.order[d.next] = key
+	d.seen[key] = dedupeEntry{at: now, slot: d.next}
+	d.next = (d.next + 1) % len(d.order)
+	return true
+}
+
+func (d *dedupeWindow) release(key string) {
+	d.mu.Lock()
+	delete(d.seen, key)
+	d.mu.Unlock()
+}
+
+// IngestMetrics son los contadores del consumidor AMQP
+type IngestMetrics struct {
+	DuplicadosDescartados int64 `json:"duplicados_descartados"`
+}
+
+func Metrics() IngestMetrics {
+	return IngestMetrics{DuplicadosDescartados: dedupe().duplicados.Load()}
+}
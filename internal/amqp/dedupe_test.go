@@ -0,0 +1,64 @@
+package amqp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupeWindowReserve(t *testing.T) {
+	d := newDedupeWindow(10, time.Minute)
+	if !d.reserve("a") {
+		t.Fatal("primera reserva rechazada")
+	}
+	if d.reserve("a") {
+		t.Fatal("duplicado aceptado")
+	}
+	if n := d.duplicados.Load(); n != 1 {
+		t.Fatalf("duplicados = %d, se esperaba 1", n)
+	}
+}
+
+func TestDedupeWindowTTL(t *testing.T) {
+	d := newDedupeWindow(10, 20*time.Millisecond)
+	d.reserve("a")
+	time.Sleep(30 * time.Millisecond)
+	if !d.reserve("a") {
+		t.Fatal("la clave vencida sigue tomándose como duplicado")
+	}
+	if d.reserve("a") {
+		t.Fatal("la clave renovada no se tomó como duplicado")
+	}
+}
+
+func TestDedupeWindowEviction(t *testing.T) {
+	d := newDedupeWindow(2, time.Minute)
+	d.reserve("a")
+	d.reserve("b")
+	d.reserve("c") // pisa a "a"
+
+	if d.reserve("b") {
+		t.Fatal("b salió de la ventana antes de tiempo")
+	}
+	if !d.reserve("a") {
+		t.Fatal("a sigue en la ventana después de llenarse")
+	}
+	if len(d.seen) > 2 {
+		t.Fatalf("la ventana guarda %d claves, capacidad 2", len(d.seen))
+	}
+}
+
+func TestDedupeWindowRelease(t *testing.T) {
+	d := newDedupeWindow(3, time.Minute)
+	d.reserve("a") // slot 0
+	d.release("a")
+	if !d.reserve("a") { // slot 1
+		t.Fatal("después de release la clave no se puede volver a reservar")
+	}
+
+	// Al volver al slot 0 el anillo no debe borrar la reserva nueva de "a"
+	d.reserve("b") // slot 2
+	d.reserve("c") // slot 0
+	if d.reserve("a") {
+		t.Fatal("el anillo borró la reserva vigente al pisar un slot viejo")
+	}
+}
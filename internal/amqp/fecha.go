@@ -0,0 +1,135 @@
+package amqp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // DEVICE_TIMEZONE funciona aunque la imagen no traiga zoneinfo
+
+	"WEBSOCKER_EASYGROW/utils"
+)
+
+const (
+	defaultSkewFuture = 2 * time.Minute
+	defaultSkewPast   = 24 * time.Hour
+)
+
+// FechaDispositivo es la fecha tal como la manda el ESP32: texto
+// ("2024-05-01T10:00:00Z", "2024-05-01 10:00:00") o epoch en segundos o
+// milisegundos, como número o como texto
+type FechaDispositivo string
+
+func (f *FechaDispositivo) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*f = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		s, err := strconv.Unquote(string(data))
+		if err != nil {
+			return err
+		}
+		*f = FechaDispositivo(s)
+		return nil
+	}
+	if _, err := strconv.ParseFloat(string(data), 64); err != nil {
+		return fmt.Errorf("fecha inválida: %s", data)
+	}
+	*f = FechaDispositivo(data)
+	return nil
+}
+
+var layoutsSinZona = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05.000",
+	"2006-01-02T15:04:05.000",
+}
+
+var errFechaVacia = errors.New("sin fecha")
+
+// parse interpreta la fecha; las que no traen zona se toman en loc
+func (f FechaDispositivo) parse(loc *time.Location) (time.Time, error) {
+	s := strings.TrimSpace(string(f))
+	if s == "" {
+		return time.Time{}, errFechaVacia
+	}
+
+	if epoch, err := strconv.ParseFloat(s, 64); err == nil {
+		// Más de 1e12 solo puede ser milisegundos (1e12 s es el año 33658)
+		if epoch > 1e12 {
+			return time.UnixMilli(int64(epoch)), nil
+		}
+		sec := int64(epoch)
+		return time.Unix(sec, int64((epoch-float64(sec))*1e9)), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range layoutsSinZona {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("formato de fecha no reconocido: %q", s)
+}
+
+// clockPolicy decide qué hora se guarda para cada lectura
+type clockPolicy struct {
+	loc    *time.Location
+	future time.Duration
+	past   time.Duration
+}
+
+var (
+	clockOnce sync.Once
+	clockMain clockPolicy
+)
+
+// clock lee DEVICE_TIMEZONE (zona de las fechas sin zona, por defecto la
+// del servidor), CLOCK_SKEW_FUTURE y CLOCK_SKEW_PAST
+func clock() clockPolicy {
+	clockOnce.Do(func() {
+		clockMain = clockPolicy{
+			loc:    time.Local,
+			future: utils.EnvDuration("CLOCK_SKEW_FUTURE", defaultSkewFuture),
+			past:   utils.EnvDuration("CLOCK_SKEW_PAST", defaultSkewPast),
+		}
+		if tz := os.Getenv("DEVICE_TIMEZONE"); tz != "" {
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				log.Printf("⚠️ DEVICE_TIMEZONE inválido (%q), usando %s", tz, clockMain.loc)
+			} else {
+				clockMain.loc = loc
+			}
+		}
+	})
+	return clockMain
+}
+
+// readingTime devuelve la hora a guardar y si el reloj del dispositivo está
+// desfasado. Sin fecha válida o con desfase se usa la hora de recepción.
+func (p clockPolicy) readingTime(mac string, f FechaDispositivo, recibido time.Time) (time.Time, bool) {
+	t, err := f.parse(p.loc)
+	if err != nil {
+		if err != errFechaVacia {
+			log.Printf("   ⚠️ %v (MAC %s), usando hora de recepción", err, mac)
+		}
+		return recibido, false
+	}
+
+	skew := t.Sub(recibido)
+	if skew > p.future || -skew > p.past {
+		log.Printf("   ⏰ Reloj desfasado en MAC %s: fecha %s, recibido %s (%s)",
+			mac, t.Format(time.RFC3339), recibido.Format(time.RFC3339), skew.Round(time.Second))
+		return recibido, true
+	}
+	return t, false
+}
@@ -0,0 +1,119 @@
+package amqp
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFechaDispositivoUnmarshal(t *testing.T) {
+	cases := []struct {
+		json    string
+		want    FechaDispositivo
+		wantErr bool
+	}{
+		{json: `{"fecha": "2024-05-01 10:00:00"}`, want: "2024-05-01 10:00:00"},
+		{json: `{"fecha": 1714557600}`, want: "1714557600"},
+		{json: `{"fecha": 1714557600000}`, want: "1714557600000"},
+		{json: `{"fecha": "1714557600"}`, want: "1714557600"},
+		{json: `{"fecha": null}`, want: ""},
+		{json: `{}`, want: ""},
+		{json: `{"fecha": true}`, wantErr: true},
+		{json: `{"fecha": {"s": 1}}`, wantErr: true},
+	}
+	for _, tc := range cases {
+		var v struct {
+			Fecha FechaDispositivo `json:"fecha"`
+		}
+		err := json.Unmarshal([]byte(tc.json), &v)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: se esperaba error, quedó %q", tc.json, v.Fecha)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error inesperado: %v", tc.json, err)
+			continue
+		}
+		if v.Fecha != tc.want {
+			t.Errorf("%s: fecha = %q, se esperaba %q", tc.json, v.Fecha, tc.want)
+		}
+	}
+}
+
+func TestFechaDispositivoParse(t *testing.T) {
+	loc, err := time.LoadLocation("America/Argentina/Buenos_Aires") // UTC-3, sin horario de verano
+	if err != nil {
+		t.Fatal(err)
+	}
+	diezUTC := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		in      FechaDispositivo
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "2024-05-01T10:00:00Z", want: diezUTC},
+		{in: "2024-05-01T07:00:00-03:00", want: diezUTC},
+		{in: "2024-05-01T10:00:00.250Z", want: diezUTC.Add(250 * time.Millisecond)},
+		// Sin zona: se toma en DEVICE_TIMEZONE
+		{in: "2024-05-01 07:00:00", want: diezUTC},
+		{in: "2024-05-01T07:00:00", want: diezUTC},
+		{in: "2024-05-01 07:00:00.500", want: diezUTC.Add(500 * time.Millisecond)},
+		// Epoch en segundos (con o sin decimales) y en milisegundos
+		{in: "1714557600", want: diezUTC},
+		{in: "1714557600.5", want: diezUTC.Add(500 * time.Millisecond)},
+		{in: "1714557600000", want: diezUTC},
+		{in: "1714557600250", want: diezUTC.Add(250 * time.Millisecond)},
+		{in: " 2024-05-01T10:00:00Z ", want: diezUTC},
+		{in: "", wantErr: true},
+		{in: "01/05/2024 10:00", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := tc.in.parse(loc)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: se esperaba error, quedó %s", tc.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: error inesperado: %v", tc.in, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%q: %s, se esperaba %s", tc.in, got.UTC(), tc.want)
+		}
+	}
+}
+
+func TestReadingTimeSkew(t *testing.T) {
+	p := clockPolicy{loc: time.UTC, future: 2 * time.Minute, past: 24 * time.Hour}
+	recibido := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	fecha := func(d time.Duration) FechaDispositivo {
+		return FechaDispositivo(recibido.Add(d).Format(time.RFC3339))
+	}
+
+	cases := []struct {
+		name        string
+		in          FechaDispositivo
+		want        time.Time
+		wantDesfase bool
+	}{
+		{name: "en hora", in: fecha(-30 * time.Second), want: recibido.Add(-30 * time.Second)},
+		{name: "adelantado dentro del límite", in: fecha(2 * time.Minute), want: recibido.Add(2 * time.Minute)},
+		{name: "adelantado de más", in: fecha(3 * time.Minute), want: recibido, wantDesfase: true},
+		{name: "atrasado dentro del límite", in: fecha(-23 * time.Hour), want: recibido.Add(-23 * time.Hour)},
+		{name: "atrasado de más", in: fecha(-25 * time.Hour), want: recibido, wantDesfase: true},
+		{name: "sin fecha", in: "", want: recibido},
+		{name: "fecha ilegible", in: "ayer", want: recibido},
+	}
+	for _, tc := range cases {
+		got, desfase := p.readingTime("AA:BB:CC:DD:EE:FF", tc.in, recibido)
+		if !got.Equal(tc.want) || desfase != tc.wantDesfase {
+			t.Errorf("%s: %s desfase=%v, se esperaba %s desfase=%v",
+				tc.name, got, desfase, tc.want, tc.wantDesfase)
+		}
+	}
+}
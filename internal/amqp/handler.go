@@ -0,0 +1,172 @@
+package amqp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"WEBSOCKER_EASYGROW/internal/alerts"
+	"WEBSOCKER_EASYGROW/internal/websocket"
+	"WEBSOCKER_EASYGROW/utils"
+)
+
+// Motivo de dead-letter para mensajes que no pasan Validate
+const motivoInvalido = "mensaje_invalido"
+
+// Handler procesa un tipo de mensaje. El consumidor llama a los pasos en
+// orden: Decode y Validate (un error aquí manda el mensaje a cuarentena),
+// Persist (un error aquí lo devuelve a la cola salvo que sea poison),
+// y después Broadcast y Alert, que solo registran sus errores.
+// El registro que devuelve Decode se pasa tal cual a los demás pasos.
+type Handler interface {
+	Name() string
+	Decode(body []byte) (interface{}, error)
+	Validate(rec interface{}) error
+	Persist(rec interface{}) error
+	Broadcast(rec interface{})
+	Alert(rec interface{})
+}
+
+// Deps son las dependencias compartidas que reciben los handlers al crearse
+type Deps struct {
+	DB        *sql.DB
+	Hub       *websocket.Hub
+	Notifiers Notifiers
+}
+
+// Notifiers son los canales por los que salen las notificaciones, armados
+// al arrancar con alerts.RegistryFromEnv
+type Notifiers struct {
+	Critical *alerts.Registry // valores críticos de sensores
+	Info     *alerts.Registry // avisos informativos, como bomba activada
+}
+
+// NotifiersFromEnv usa ALERT_CHANNELS (por defecto telegram, email, SMS y
+// WhatsApp) y ALERT_CHANNELS_INFO (por defecto solo Telegram, menos invasivo)
+func NotifiersFromEnv() Notifiers {
+	return Notifiers{
+		Critical: alerts.RegistryFromEnv("ALERT_CHANNELS", "telegram,email,sms,whatsapp"),
+		Info:     alerts.RegistryFromEnv("ALERT_CHANNELS_INFO", "telegram"),
+	}
+}
+
+const defaultNotifyTimeout = time.Minute
+
+// notify envía en segundo plano para no frenar el consumidor
+func notify(registry *alerts.Registry, to alerts.Recipient, msg alerts.Message) {
+	if registry == nil {
+		return
+	}
+	timeout := utils.EnvDuration("ALERT_TIMEOUT", defaultNotifyTimeout)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		registry.Send(ctx, to, msg)
+	}()
+}
+
+type routeKey struct {
+	queue      string
+	routingKey string
+}
+
+// Registry asocia handlers a colas y, opcionalmente, a routing keys dentro
+// de una cola. Una routing key sin handler propio usa el de la cola.
+type Registry struct {
+	routes map[routeKey]Handler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{routes: make(map[routeKey]Handler)}
+}
+
+// Register atiende todos los mensajes de la cola con el handler
+func (r *Registry) Register(queue string, h Handler) {
+	r.RegisterRoutingKey(queue, "", h)
+}
+
+// RegisterRoutingKey atiende solo los mensajes de la cola con esa routing key
+func (r *Registry) RegisterRoutingKey(queue, routingKey string, h Handler) {
+	r.routes[routeKey{queue: queue, routingKey: routingKey}] = h
+	if routingKey == "" {
+		log.Printf("🧩 Handler %s registrado para cola %s", h.Name(), queue)
+	} else {
+		log.Printf("🧩 Handler %s registrado para cola %s (routing key %s)", h.Name(), queue, routingKey)
+	}
+}
+
+func (r *Registry) lookup(queue, routingKey string) (Handler, bool) {
+	if h, ok := r.routes[routeKey{queue: queue, routingKey: routingKey}]; ok {
+		return h, true
+	}
+	h, ok := r.routes[routeKey{queue: queue}]
+	return h, ok
+}
+
+// Queues devuelve las colas con al menos un handler, ordenadas
+func (r *Registry) Queues() []string {
+	seen := map[string]bool{}
+	var queues []string
+	for k := range r.routes {
+		if !seen[k.queue] {
+			seen[k.queue] = true
+			queues = append(queues, k.queue)
+		}
+	}
+	sort.Strings(queues)
+	return queues
+}
+
+// decode convierte el cuerpo en el registro del handler y lo valida.
+// Cualquier error aquí es definitivo (poison).
+func decode(h Handler, body []byte) (interface{}, error) {
+	rec, err := h.Decode(body)
+	if err != nil {
+		log.Printf("   ❌ Error parseando %s: %v", h.Name(), err)
+		return nil, poison(motivoJSONInvalido, err)
+	}
+	if err := h.Validate(rec); err != nil {
+		log.Printf("   ❌ %s inválido: %v", h.Name(), err)
+		var pe *poisonError
+		if errors.As(err, &pe) {
+			return nil, err
+		}
+		return nil, poison(motivoInvalido, err)
+	}
+	return rec, nil
+}
+
+// process ejecuta el resto del pipeline sobre un registro ya validado
+func process(h Handler, rec interface{}) error {
+	// Guardar antes de publicar, así un reintento no duplica el WebSocket
+	return afterPersist(h, rec, h.Persist(rec))
+}
+
+// afterPersist publica y alerta solo si el guardado salió bien
+func afterPersist(h Handler, rec interface{}, err error) error {
+	if err != nil {
+		log.Printf("   ❌ Error guardando %s: %v", h.Name(), err)
+		return err
+	}
+
+	h.Broadcast(rec)
+	h.Alert(rec)
+	return nil
+}
+
+// asyncPersister lo implementan los handlers que guardan en lotes. El
+// worker no espera: done se llama una vez que el guardado se confirmó o
+// falló, desde cualquier goroutine y sin bloquear, y el worker publica y
+// confirma el mensaje a RabbitMQ cuando le toca.
+type asyncPersister interface {
+	PersistAsync(rec interface{}, done func(error))
+}
+
+// shardKeyer lo implementan los registros que deben procesarse en orden
+// por alguna clave (la MAC del dispositivo)
+type shardKeyer interface {
+	shardKey() string
+}
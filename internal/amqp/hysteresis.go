@@ -0,0 +1,154 @@
+package amqp
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// regla dice cuánto tiene que durar un valor crítico antes de levantar la
+// alerta (muestras seguidas o tiempo, lo que se cumpla primero) y cuánto
+// tiene que volver hacia el rango normal para darla por superada
+type regla struct {
+	muestras int
+	duracion time.Duration
+	margen   float64 // distancia desde el límite crítico para recuperarse
+}
+
+// Reglas por defecto por tipo de sensor; lluvia y vibración son eventos
+// digitales y alertan con la primera muestra
+var reglasPorDefecto = map[string]regla{
+	sensorTemperatura:  {muestras: 3, duracion: 2 * time.Minute, margen: 1},
+	sensorHumedad:      {muestras: 3, duracion: 2 * time.Minute, margen: 3},
+	sensorLuminosidad:  {muestras: 3, duracion: 5 * time.Minute, margen: 10},
+	sensorNivelAgua:    {muestras: 3, duracion: 2 * time.Minute, margen: 1},
+	sensorHumedadSuelo: {muestras: 3, duracion: 5 * time.Minute, margen: 100},
+}
+
+var reglaInmediata = regla{muestras: 1}
+
+// condicion evalúa una lectura contra su umbral y su regla
+type condicion struct {
+	regla      regla
+	enCritico  func(v float64) bool
+	recuperado func(v float64) bool
+}
+
+// condicionFor arma la condición del sensor: el umbral de umbralFor y la
+// regla del tipo de sensor, que la fila de umbrales_alerta puede pisar
+func condicionFor(sensorID int, plantaID sql.NullInt64, nombre string) condicion {
+	r, ok := reglasPorDefecto[tipoSensor(nombre)]
+	if !ok {
+		r = reglaInmediata
+	}
+
+	u, ok := umbralFor(sensorID, plantaID, nombre)
+	if !ok {
+		critical := func(v float64) bool { return isCritical(nombre, v) }
+		return condicion{
+			regla:      r,
+			enCritico:  critical,
+			recuperado: func(v float64) bool { return !critical(v) },
+		}
+	}
+
+	if u.muestras.Valid && u.muestras.Int64 > 0 {
+		r.muestras = int(u.muestras.Int64)
+	}
+	if u.duracionSeg.Valid {
+		r.duracion = time.Duration(u.duracionSeg.Int64) * time.Second
+	}
+	if u.margen.Valid {
+		r.margen = u.margen.Float64
+	}
+
+	// Rango de recuperación: el crítico achicado por el margen
+	recMin, recMax := u.critMin, u.critMax
+	recMin.Float64 += r.margen
+	recMax.Float64 -= r.margen
+	return condicion{
+		regla:      r,
+		enCritico:  func(v float64) bool { return outside(v, u.critMin, u.critMax) },
+		recuperado: func(v float64) bool { return !outside(v, recMin, recMax) },
+	}
+}
+
+// estadoSensor es el estado de alerta de un sensor de un dispositivo
+type estadoSensor struct {
+	critico bool
+	racha   int       // muestras seguidas fuera del rango crítico
+	desde   time.Time // fecha de la primera muestra de la racha
+}
+
+// sensorStates guarda el estado en memoria por MAC y sensor. Como las
+// lecturas de una MAC se procesan en orden en el mismo worker, cada
+// sensor ve sus muestras en secuencia. Al reiniciar, cada sensor arranca
+// en crítico si tiene una alerta sin resolver en la BD.
+type sensorStates struct {
+	mu      sync.Mutex
+	estados map[sensorKey]*estadoSensor
+}
+
+var (
+	statesOnce sync.Once
+	statesMain *sensorStates
+)
+
+func states() *sensorStates {
+	statesOnce.Do(func() {
+		statesMain = &sensorStates{estados: make(map[sensorKey]*estadoSensor)}
+	})
+	return statesMain
+}
+
+// evaluate aplica la muestra y devuelve si el sensor está en crítico y si
+// esta muestra cambió el estado (levantó o superó la alerta). La primera
+// vez que ve el sensor toma el estado de abierta, que consulta la BD; si
+// la consulta falla la muestra no se evalúa y se reintenta con la próxima.
+func (s *sensorStates) evaluate(mac, nombre string, c condicion, valor float64, at time.Time, abierta func() (bool, error)) (critico, cambio bool) {
+	key := sensorKey{mac: strings.ToUpper(mac), nombre: nombre}
+	s.mu.Lock()
+	st, ok := s.estados[key]
+	s.mu.Unlock()
+	if !ok {
+		// La consulta va fuera del lock para no frenar a los demás workers
+		enCritico, err := abierta()
+		if err != nil {
+			log.Printf("   ❌ Error consultando alertas abiertas de %s: %v", nombre, err)
+			return false, false
+		}
+		s.mu.Lock()
+		if st, ok = s.estados[key]; !ok {
+			st = &estadoSensor{critico: enCritico}
+			s.estados[key] = st
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st.critico {
+		if c.recuperado(valor) {
+			*st = estadoSensor{}
+			return false, true
+		}
+		return true, false
+	}
+
+	if !c.enCritico(valor) {
+		st.racha = 0
+		return false, false
+	}
+	if st.racha == 0 {
+		st.desde = at
+	}
+	st.racha++
+	if st.racha >= c.regla.muestras || (c.regla.duracion > 0 && at.Sub(st.desde) >= c.regla.duracion) {
+		st.critico, st.racha = true, 0
+		return true, true
+	}
+	return false, false
+}
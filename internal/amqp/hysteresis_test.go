@@ -0,0 +1,157 @@
+package amqp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Crítico por encima de 35, recuperado a 34 o menos
+func condicionTest(r regla) condicion {
+	return condicion{
+		regla:      r,
+		enCritico:  func(v float64) bool { return v > 35 },
+		recuperado: func(v float64) bool { return v <= 34 },
+	}
+}
+
+type muestra struct {
+	valor   float64
+	at      time.Duration // desde la primera muestra
+	critico bool
+	cambio  bool
+}
+
+// semilla devuelve las respuestas de abierta() en orden
+type semilla struct {
+	abierta bool
+	err     error
+}
+
+func TestSensorStatesEvaluate(t *testing.T) {
+	errBD := errors.New("bd caída")
+
+	cases := []struct {
+		name     string
+		regla    regla
+		semillas []semilla
+		muestras []muestra
+	}{
+		{
+			name:  "levanta con N muestras",
+			regla: regla{muestras: 3},
+			muestras: []muestra{
+				{valor: 36}, {valor: 37}, {valor: 36, critico: true, cambio: true},
+				{valor: 38, critico: true},
+			},
+		},
+		{
+			name:  "levanta por duración",
+			regla: regla{muestras: 10, duracion: 2 * time.Minute},
+			muestras: []muestra{
+				{valor: 36}, {valor: 36, at: time.Minute},
+				{valor: 36, at: 2 * time.Minute, critico: true, cambio: true},
+			},
+		},
+		{
+			name:  "la racha se corta al salir del rango crítico",
+			regla: regla{muestras: 3},
+			muestras: []muestra{
+				{valor: 36}, {valor: 36}, {valor: 30}, {valor: 36}, {valor: 36},
+				{valor: 36, critico: true, cambio: true},
+			},
+		},
+		{
+			name:  "la duración cuenta desde el inicio de la racha nueva",
+			regla: regla{muestras: 10, duracion: 2 * time.Minute},
+			muestras: []muestra{
+				{valor: 36}, {valor: 30, at: time.Minute}, {valor: 36, at: 90 * time.Second},
+				{valor: 36, at: 3 * time.Minute},
+				{valor: 36, at: 210 * time.Second, critico: true, cambio: true},
+			},
+		},
+		{
+			name:  "sigue crítico dentro de la banda de recuperación",
+			regla: regla{muestras: 1},
+			muestras: []muestra{
+				{valor: 36, critico: true, cambio: true},
+				{valor: 34.5, critico: true}, {valor: 35, critico: true},
+			},
+		},
+		{
+			name:  "se supera recién pasado el margen",
+			regla: regla{muestras: 1},
+			muestras: []muestra{
+				{valor: 36, critico: true, cambio: true},
+				{valor: 34.5, critico: true},
+				{valor: 34, cambio: true},
+				{valor: 34.5},
+			},
+		},
+		{
+			name:     "arranca en crítico si hay una alerta abierta",
+			regla:    regla{muestras: 3},
+			semillas: []semilla{{abierta: true}},
+			muestras: []muestra{
+				{valor: 34.5, critico: true},
+				{valor: 33, cambio: true},
+			},
+		},
+		{
+			name:     "alerta abierta y primera muestra ya recuperada",
+			regla:    regla{muestras: 3},
+			semillas: []semilla{{abierta: true}},
+			muestras: []muestra{{valor: 30, cambio: true}},
+		},
+		{
+			name:     "sin alerta abierta la primera muestra normal no cambia nada",
+			regla:    regla{muestras: 3},
+			semillas: []semilla{{abierta: false}},
+			muestras: []muestra{{valor: 30}},
+		},
+		{
+			name:     "si la consulta falla se reintenta con la próxima muestra",
+			regla:    regla{muestras: 1},
+			semillas: []semilla{{err: errBD}, {abierta: true}},
+			muestras: []muestra{
+				{valor: 36},
+				{valor: 34.5, critico: true},
+				{valor: 30, cambio: true},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &sensorStates{estados: make(map[sensorKey]*estadoSensor)}
+			consultas := 0
+			abierta := func() (bool, error) {
+				consultas++
+				if consultas > len(tc.semillas) {
+					return false, nil
+				}
+				sem := tc.semillas[consultas-1]
+				return sem.abierta, sem.err
+			}
+
+			start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+			c := condicionTest(tc.regla)
+			for i, m := range tc.muestras {
+				critico, cambio := s.evaluate("aa:bb:cc:dd:ee:ff", "Temperatura", c, m.valor, start.Add(m.at), abierta)
+				if critico != m.critico || cambio != m.cambio {
+					t.Fatalf("muestra %d (%.1f): critico=%v cambio=%v, se esperaba critico=%v cambio=%v",
+						i, m.valor, critico, cambio, m.critico, m.cambio)
+				}
+			}
+
+			// La BD se consulta solo hasta que el sensor tiene estado
+			want := len(tc.semillas)
+			if want == 0 {
+				want = 1
+			}
+			if consultas != want {
+				t.Fatalf("abierta() llamada %d veces, se esperaban %d", consultas, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package amqp
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"WEBSOCKER_EASYGROW/utils"
+)
+
+const (
+	defaultIDCacheTTL      = 5 * time.Minute
+	defaultIDCacheNegative = time.Minute
+)
+
+type sensorKey struct {
+	mac    string
+	nombre string
+}
+
+type cachedSensor struct {
+	id      int
+	found   bool
+	expires time.Time
+}
+
+type cachedPlanta struct {
+	id      sql.NullInt64
+	expires time.Time
+}
+
+// idCache guarda la resolución MAC+nombre → id_sensor y MAC → id_planta,
+// que casi nunca cambian. Los sensores desconocidos también se guardan
+// (por ID_CACHE_NEGATIVE_TTL) para que un dispositivo mal configurado no
+// golpee MySQL con cada lectura.
+type idCache struct {
+	ttl      time.Duration
+	negative time.Duration
+
+	mu       sync.RWMutex
+	sensores map[sensorKey]cachedSensor
+	plantas  map[string]cachedPlanta
+}
+
+// La caché se crea al primer uso, ya con las variables de entorno cargadas
+var (
+	idsOnce sync.Once
+	idsMain *idCache
+)
+
+func ids() *idCache {
+	idsOnce.Do(func() { idsMain = newIDCache() })
+	return idsMain
+}
+
+func newIDCache() *idCache {
+	return &idCache{
+		ttl:      utils.EnvDuration("ID_CACHE_TTL", defaultIDCacheTTL),
+		negative: utils.EnvDuration("ID_CACHE_NEGATIVE_TTL", defaultIDCacheNegative),
+		sensores: make(map[sensorKey]cachedSensor),
+		plantas:  make(map[string]cachedPlanta),
+	}
+}
+
+func cacheMac(mac string) string {
+	return strings.ToUpper(strings.TrimSpace(mac))
+}
+
+// sensorID resuelve el sensor activo; un sensor no registrado es poison
+func (c *idCache) sensorID(dbConn *sql.DB, mac, nombre string) (int, error) {
+	key := sensorKey{mac: cacheMac(mac), nombre: nombre}
+	c.mu.RLock()
+	cached, ok := c.sensores[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(cached.expires) {
+		var id int
+		err := dbConn.QueryRow(`
+			SELECT s.id_sensor
+			FROM sensor_datos s
+			JOIN dispositivo d ON s.id_dispositivo = d.id_dispositivo
+			WHERE d.mac_address = ? AND s.nombre_sensor = ? AND s.activo = 1
+		`, mac, nombre).Scan(&id)
+		switch {
+		case err == sql.ErrNoRows:
+			cached = cachedSensor{expires: time.Now().Add(c.negative)}
+		case err != nil:
+			log.Printf("❌ Error obteniendo sensor para MAC %s, nombre %s: %v", mac, nombre, err)
+			return 0, err
+		default:
+			cached = cachedSensor{id: id, found: true, expires: time.Now().Add(c.ttl)}
+		}
+		c.mu.Lock()
+		c.sensores[key] = cached
+		c.mu.Unlock()
+	}
+
+	if !cached.found {
+		return 0, poison(motivoSensorDesconocido,
+			fmt.Errorf("sensor %q no registrado para MAC %s", nombre, mac))
+	}
+	return cached.id, nil
+}
+
+// plantaID resuelve la planta activa del dispositivo; sin planta devuelve
+// un NullInt64 inválido, que también se guarda
+func (c *idCache) plantaID(dbConn *sql.DB, mac string) (sql.NullInt64, error) {
+	key := cacheMac(mac)
+	c.mu.RLock()
+	cached, ok := c.plantas[key]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.id, nil
+	}
+
+	var id sql.NullInt64
+	err := dbConn.QueryRow(`
+		SELECT p.id_planta
+		FROM planta p
+		JOIN dispositivo d ON p.id_dispositivo = d.id_dispositivo
+		WHERE d.mac_address = ? AND p.activa = 1
+		LIMIT 1
+	`, mac).Scan(&id)
+	ttl := c.ttl
+	switch {
+	case err == sql.ErrNoRows:
+		ttl = c.negative
+	case err != nil:
+		return sql.NullInt64{}, err
+	}
+
+	c.mu.Lock()
+	c.plantas[key] = cachedPlanta{id: id, expires: time.Now().Add(ttl)}
+	c.mu.Unlock()
+	return id, nil
+}
+
+// invalidate borra lo guardado para la MAC (o todo si mac es "") y
+// devuelve cuántas entradas se borraron
+func (c *idCache) invalidate(mac string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if mac == "" {
+		n := len(c.sensores) + len(c.plantas)
+		c.sensores = make(map[sensorKey]cachedSensor)
+		c.plantas = make(map[string]cachedPlanta)
+		return n
+	}
+
+	mac = cacheMac(mac)
+	n := 0
+	for k := range c.sensores {
+		if k.mac == mac {
+			delete(c.sensores, k)
+			n++
+		}
+	}
+	if _, ok := c.plantas[mac]; ok {
+		delete(c.plantas, mac)
+		n++
+	}
+	return n
+}
+
+// InvalidateIDCache olvida los ids resueltos de un dispositivo (o de
+// todos si mac es "") en esta instancia, por ejemplo después de dar de
+// alta un sensor o cambiar la planta
+func InvalidateIDCache(mac string) int {
+	n := ids().invalidate(mac)
+	if mac == "" {
+		log.Printf("🧹 Caché de ids vaciada (%d entradas)", n)
+	} else {
+		log.Printf("🧹 Caché de ids invalidada para MAC %s (%d entradas)", mac, n)
+	}
+	return n
+}
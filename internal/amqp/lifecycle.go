@@ -0,0 +1,104 @@
+package amqp
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"WEBSOCKER_EASYGROW/internal/websocket"
+)
+
+// Estados de una alerta: abierta → reconocida → resuelta (o abierta → resuelta)
+const (
+	estadoAbierta    = "abierta"
+	estadoReconocida = "reconocida"
+	estadoResuelta   = "resuelta"
+)
+
+// alertRef es lo necesario para publicar un cambio de estado de la alerta
+type alertRef struct {
+	id         int64
+	plantaID   int
+	tipoAlerta string
+	mac        string
+	nombre     string
+	estado     string
+}
+
+// publishTransition avisa por WebSocket al dueño que la alerta cambió de estado
+func publishTransition(hub *websocket.Hub, ownerID int, ref alertRef, estado string, actor int) {
+	if ownerID == 0 {
+		return
+	}
+	hub.Publish(websocket.Message{
+		Tipo:       websocket.TipoAlertaEstado,
+		MacAddress: ref.mac,
+		Nombre:     ref.nombre,
+		IDUsuario:  ownerID,
+		Payload: websocket.AlertaEstado{
+			IDAlerta:       ref.id,
+			IDPlanta:       ref.plantaID,
+			TipoAlerta:     ref.tipoAlerta,
+			MacAddress:     ref.mac,
+			Nombre:         ref.nombre,
+			Estado:         estado,
+			EstadoAnterior: ref.estado,
+			IDActor:        actor,
+			Fecha:          time.Now().UTC(),
+		},
+	})
+}
+
+// hasUnresolved dice si el dispositivo tiene alguna alerta sin resolver
+// del sensor y tipo
+func hasUnresolved(dbConn *sql.DB, mac, nombre, tipoAlerta string) (bool, error) {
+	var exists bool
+	err := dbConn.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM alertas
+		              WHERE mac_address = ? AND nombre_sensor = ? AND tipo_alerta = ? AND estado <> ?)
+	`, mac, nombre, tipoAlerta, estadoResuelta).Scan(&exists)
+	return exists, err
+}
+
+// resolveAlerts cierra las alertas no resueltas del dispositivo, sensor y
+// tipo, y devuelve las que cerró esta llamada con su estado anterior
+func resolveAlerts(dbConn *sql.DB, mac, nombre, tipoAlerta string) ([]alertRef, error) {
+	rows, err := dbConn.Query(`
+		SELECT id_alerta, id_planta, estado
+		FROM alertas
+		WHERE mac_address = ? AND nombre_sensor = ? AND tipo_alerta = ? AND estado <> ?
+	`, mac, nombre, tipoAlerta, estadoResuelta)
+	if err != nil {
+		return nil, err
+	}
+	var pendientes []alertRef
+	for rows.Next() {
+		ref := alertRef{tipoAlerta: tipoAlerta, mac: mac, nombre: nombre}
+		if err := rows.Scan(&ref.id, &ref.plantaID, &ref.estado); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		pendientes = append(pendientes, ref)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var resueltas []alertRef
+	for _, ref := range pendientes {
+		// El estado en el WHERE evita pisar una resolución concurrente
+		res, err := dbConn.Exec(`
+			UPDATE alertas SET estado = ?, fecha_resuelta = NOW()
+			WHERE id_alerta = ? AND estado <> ?
+		`, estadoResuelta, ref.id, estadoResuelta)
+		if err != nil {
+			return resueltas, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			log.Printf("   ✅ Alerta %d resuelta automáticamente (estaba %s)", ref.id, ref.estado)
+			resueltas = append(resueltas, ref)
+		}
+	}
+	return resueltas, nil
+}
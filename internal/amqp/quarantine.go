@@ -0,0 +1,167 @@
+package amqp
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	ErrQuarantineUnavailable = errors.New("cuarentena no disponible")
+	ErrQuarantineNotFound    = errors.New("mensaje en cuarentena no encontrado")
+)
+
+const defaultQuarantineLimit = 50
+
+// Motivos que van a cuarentena en vez de a dead-letter: el mensaje llegó
+// mal armado y se puede corregir (o arreglar el firmware) y re-enviar
+var motivosCuarentena = map[string]bool{
+	motivoJSONInvalido: true,
+	motivoInvalido:     true,
+}
+
+// QuarantinedMessage es una fila de cuarentena_mensajes
+type QuarantinedMessage struct {
+	ID            int64  `json:"id_cuarentena"`
+	Cola          string `json:"cola"`
+	RoutingKey    string `json:"routing_key,omitempty"`
+	Motivo        string `json:"motivo"`
+	Detalle       string `json:"detalle"`
+	Cuerpo        string `json:"cuerpo"`
+	MessageID     string `json:"message_id,omitempty"`
+	FechaRecibido string `json:"fecha_recibido"`
+	Reenviado     bool   `json:"reenviado"`
+	FechaReenvio  string `json:"fecha_reenvio,omitempty"`
+}
+
+// quarantineStore guarda en MySQL los mensajes que no pasan la validación
+// y los vuelve a publicar en su cola cuando se piden desde /admin
+type quarantineStore struct {
+	db *sql.DB
+	ch atomic.Pointer[amqp.Channel] // lo reemplaza cada sesión AMQP
+}
+
+var quarantineMain atomic.Pointer[quarantineStore]
+
+func newQuarantineStore(db *sql.DB) *quarantineStore {
+	q := &quarantineStore{db: db}
+	quarantineMain.Store(q)
+	return q
+}
+
+// setChannel cambia el canal para re-enviar; lo llama cada sesión AMQP
+func (q *quarantineStore) setChannel(ch *amqp.Channel) {
+	q.ch.Store(ch)
+}
+
+func (q *quarantineStore) store(queueName string, msg amqp.Delivery, reason string, cause error) error {
+	_, err := q.db.Exec(`
+		INSERT INTO cuarentena_mensajes (cola, routing_key, motivo, detalle, cuerpo, message_id, fecha_recibido)
+		VALUES (?, ?, ?, ?, ?, ?, NOW(3))
+	`, queueName, msg.RoutingKey, reason, cause.Error(), msg.Body, sql.NullString{String: msg.MessageId, Valid: msg.MessageId != ""})
+	return err
+}
+
+// quarantineOrSettle manda a cuarentena los mensajes mal armados; si no
+// es uno de esos, o no se pudo guardar, sigue el camino normal de settle
+func quarantineOrSettle(ch *amqp.Channel, queueName string, msg amqp.Delivery, err error) {
+	var pe *poisonError
+	if q := quarantineMain.Load(); q != nil && errors.As(err, &pe) && motivosCuarentena[pe.reason] {
+		qErr := q.store(queueName, msg, pe.reason, err)
+		if qErr == nil {
+			log.Printf("   🧪 Mensaje en cuarentena (%s): %v", pe.reason, err)
+			if ackErr := msg.Ack(false); ackErr != nil {
+				log.Printf("   ❌ Error confirmando mensaje: %v", ackErr)
+			}
+			return
+		}
+		log.Printf("   ❌ No se pudo guardar en cuarentena: %v", qErr)
+	}
+	settle(ch, queueName, msg, err)
+}
+
+// ListQuarantine devuelve los últimos mensajes en cuarentena, opcionalmente
+// de una sola cola, sin los ya re-enviados salvo que se pidan
+func ListQuarantine(cola string, limit int, incluirReenviados bool) ([]QuarantinedMessage, error) {
+	q := quarantineMain.Load()
+	if q == nil {
+		return nil, ErrQuarantineUnavailable
+	}
+	if limit <= 0 {
+		limit = defaultQuarantineLimit
+	}
+
+	rows, err := q.db.Query(`
+		SELECT id_cuarentena, cola, COALESCE(routing_key, ''), motivo, COALESCE(detalle, ''), cuerpo,
+		       COALESCE(message_id, ''), fecha_recibido, reenviado, COALESCE(fecha_reenvio, '')
+		FROM cuarentena_mensajes
+		WHERE (? = '' OR cola = ?) AND (? OR reenviado = 0)
+		ORDER BY id_cuarentena DESC
+		LIMIT ?
+	`, cola, cola, incluirReenviados, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mensajes := []QuarantinedMessage{}
+	for rows.Next() {
+		var m QuarantinedMessage
+		var cuerpo []byte
+		if err := rows.Scan(&m.ID, &m.Cola, &m.RoutingKey, &m.Motivo, &m.Detalle, &cuerpo,
+			&m.MessageID, &m.FechaRecibido, &m.Reenviado, &m.FechaReenvio); err != nil {
+			return nil, err
+		}
+		m.Cuerpo = string(cuerpo)
+		mensajes = append(mensajes, m)
+	}
+	return mensajes, rows.Err()
+}
+
+// ResubmitQuarantine vuelve a publicar el cuerpo original en su cola (por
+// el exchange por defecto) y marca la fila como re-enviada. Si sigue
+// siendo inválido vuelve a entrar a cuarentena como una fila nueva.
+func ResubmitQuarantine(id int64) error {
+	q := quarantineMain.Load()
+	if q == nil {
+		return ErrQuarantineUnavailable
+	}
+
+	var cola string
+	var cuerpo []byte
+	var messageID sql.NullString
+	err := q.db.QueryRow(`SELECT cola, cuerpo, message_id FROM cuarentena_mensajes WHERE id_cuarentena = ?`, id).
+		Scan(&cola, &cuerpo, &messageID)
+	if err == sql.ErrNoRows {
+		return ErrQuarantineNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	ch := q.ch.Load()
+	if ch == nil {
+		return ErrQuarantineUnavailable
+	}
+	err = ch.Publish("", cola, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    messageID.String,
+		Body:         cuerpo,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrQuarantineUnavailable, err)
+	}
+
+	if _, err := q.db.Exec(`
+		UPDATE cuarentena_mensajes SET reenviado = 1, fecha_reenvio = NOW(3) WHERE id_cuarentena = ?
+	`, id); err != nil {
+		log.Printf("⚠️ Mensaje %d re-enviado pero no se pudo marcar: %v", id, err)
+	}
+	log.Printf("🔁 Mensaje %d de cuarentena re-enviado a %s", id, cola)
+	return nil
+}
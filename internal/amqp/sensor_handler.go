@@ -0,0 +1,261 @@
+package amqp
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"WEBSOCKER_EASYGROW/internal/alerts"
+	"WEBSOCKER_EASYGROW/internal/websocket"
+)
+
+// sensorHandler procesa la cola de datos de sensores
+type sensorHandler struct {
+	deps    Deps
+	batcher *lecturaBatcher
+	window  alertWindow
+}
+
+// sensorRecord acompaña la lectura con lo que se va resolviendo en el pipeline
+type sensorRecord struct {
+	SensorData
+	sensorID int
+	plantaID sql.NullInt64
+	calidad  string
+	ownerID  int
+	leida    time.Time // fecha del dispositivo o, si no sirve, de recepción
+	desfase  bool
+}
+
+func (r *sensorRecord) shardKey() string { return strings.ToUpper(r.MacAddress) }
+func (r *sensorRecord) idempotencyFields() (string, bool) {
+	// Sin fecha, dos lecturas iguales seguidas no se distinguen de un reintento
+	if r.Fecha == "" {
+		return "", false
+	}
+	return fmt.Sprintf("%s|%s|%s|%v", strings.ToUpper(r.MacAddress), r.Nombre, r.Fecha, r.Valor), true
+}
+
+func newSensorHandler(deps Deps, queue string) *sensorHandler {
+	return &sensorHandler{
+		deps:    deps,
+		batcher: newLecturaBatcher(deps.DB, queueOptionsFor(queue).prefetch),
+		window:  alertWindowFromEnv(),
+	}
+}
+
+func (h *sensorHandler) Name() string { return "sensor data" }
+
+func (h *sensorHandler) Decode(body []byte) (interface{}, error) {
+	rec := &sensorRecord{}
+	if err := json.Unmarshal(body, &rec.SensorData); err != nil {
+		return nil, err
+	}
+	rec.leida, rec.desfase = clock().readingTime(rec.MacAddress, rec.Fecha, time.Now())
+	return rec, nil
+}
+
+func (h *sensorHandler) Validate(r interface{}) error {
+	rec := r.(*sensorRecord)
+	if err := validateSensorData(rec.SensorData); err != nil {
+		return err
+	}
+
+	log.Printf("   📊 SENSOR DATA: %s = %.2f", rec.Nombre, rec.Valor)
+	log.Printf("      MAC: %s", rec.MacAddress)
+	return nil
+}
+
+func (h *sensorHandler) Persist(r interface{}) error {
+	done := make(chan error, 1)
+	h.PersistAsync(r, func(err error) { done <- err })
+	return <-done
+}
+
+// PersistAsync resuelve sensor y planta en el worker y deja el INSERT al
+// lecturaBatcher; done se llama después del commit del lote
+func (h *sensorHandler) PersistAsync(r interface{}, done func(error)) {
+	rec := r.(*sensorRecord)
+	row, err := resolveSensorReading(h.deps.DB, rec.SensorData, rec.leida, rec.desfase)
+	if err != nil {
+		done(err)
+		return
+	}
+	rec.sensorID, rec.plantaID, rec.calidad = row.sensorID, row.plantaID, row.calidad
+	h.batcher.add(row, done)
+}
+
+// Enviar a WebSocket (solo al dueño del dispositivo, si está suscrito)
+func (h *sensorHandler) Broadcast(r interface{}) {
+	rec := r.(*sensorRecord)
+	ownerID, err := getUserIDByMac(h.deps.DB, rec.MacAddress)
+	if err != nil {
+		log.Printf("   ⚠️ No se envía a WebSocket: %v", err)
+		return
+	}
+	rec.ownerID = ownerID
+
+	h.publish(rec, websocket.TipoSensor, rec.SensorData)
+	h.publish(rec, websocket.TipoCalidad, websocket.CalidadDato{
+		MacAddress: rec.MacAddress,
+		Nombre:     rec.Nombre,
+		IDSensor:   rec.sensorID,
+		Valor:      rec.Valor,
+		Calidad:    rec.calidad,
+	})
+	log.Println("   📤 Enviado a WebSocket")
+}
+
+func (h *sensorHandler) publish(rec *sensorRecord, tipo string, payload interface{}) {
+	if rec.ownerID == 0 {
+		return
+	}
+	h.deps.Hub.Publish(websocket.Message{
+		Tipo:       tipo,
+		MacAddress: rec.MacAddress,
+		Nombre:     rec.Nombre,
+		IDUsuario:  rec.ownerID,
+		Payload:    payload,
+	})
+}
+
+// Verificar si es crítico y crear alerta. La alerta se levanta recién
+// cuando el valor se sostiene fuera de rango (ver condicionFor); dentro
+// del cooldown solo se cuenta la repetición y cada tanto se recuerda.
+// Cuando el valor vuelve al rango normal las alertas abiertas se resuelven.
+func (h *sensorHandler) Alert(r interface{}) {
+	rec := r.(*sensorRecord)
+	cond := condicionFor(rec.sensorID, rec.plantaID, rec.Nombre)
+	tipoAlerta := tipoAlertaFor(rec.Nombre)
+	critico, cambio := states().evaluate(rec.MacAddress, rec.Nombre, cond, rec.Valor, rec.leida, func() (bool, error) {
+		return hasUnresolved(h.deps.DB, rec.MacAddress, rec.Nombre, tipoAlerta)
+	})
+	if cambio && !critico {
+		h.resolve(rec)
+		return
+	}
+	if !critico {
+		if rec.calidad == calidadCritico {
+			log.Printf("   ⏳ Valor crítico todavía no sostenido, no se alerta")
+		}
+		return
+	}
+	if !cond.enCritico(rec.Valor) {
+		// Sigue en crítico pero ya dentro de la banda de recuperación: la
+		// alerta queda abierta sin contar la lectura ni recordar
+		if err := h.window.keepOpen(h.deps.DB, rec.MacAddress, rec.Nombre, tipoAlerta); err != nil {
+			log.Printf("   ❌ Error renovando alerta de %s: %v", rec.Nombre, err)
+		}
+		return
+	}
+
+	open, err := h.window.findOpen(h.deps.DB, rec.MacAddress, rec.Nombre, tipoAlerta)
+	if err != nil {
+		log.Printf("   ❌ Error buscando alerta abierta: %v", err)
+		return
+	}
+
+	if open != nil {
+		if err := h.window.repeat(h.deps.DB, open); err != nil {
+			log.Printf("   ❌ Error actualizando alerta %d: %v", open.id, err)
+			return
+		}
+		if !open.recordar {
+			return
+		}
+		log.Printf("   ⏰ Recordatorio: alerta %d sigue crítica", open.id)
+		h.publish(rec, websocket.TipoAlerta, &websocket.Alerta{
+			IDAlerta:     open.id,
+			IDPlanta:     open.plantaID,
+			TipoAlerta:   tipoAlerta,
+			Nivel:        "critico",
+			Mensaje:      fmt.Sprintf("Sigue crítico: %.2f en %s (Dispositivo: %s)", rec.Valor, rec.Nombre, rec.MacAddress),
+			MacAddress:   rec.MacAddress,
+			Nombre:       rec.Nombre,
+			Valor:        rec.Valor,
+			Repeticiones: open.repeticiones,
+		})
+		h.notifyOwner(rec, aviso{
+			subject: "🚨 ALERTA CRÍTICA - EasyGrow",
+			titulo:  "⏰ <b>SIGUE CRÍTICO - SENSOR</b>",
+			extra:   fmt.Sprintf("🔁 <b>Lecturas críticas:</b> %d\n", open.repeticiones),
+			pie:     pieCritico,
+		})
+		return
+	}
+	log.Printf("   🚨 VALOR CRÍTICO DETECTADO")
+
+	// Crear alerta en BD. Sin fila no hay cooldown que frene la próxima
+	// lectura, así que tampoco se notifica: se avisaría en cada muestra
+	alerta := createAlert(h.deps.DB, rec.MacAddress, rec.Nombre, rec.Valor)
+	if alerta == nil {
+		log.Printf("   ⚠️ Alerta no registrada, no se notifica")
+		return
+	}
+	h.publish(rec, websocket.TipoAlerta, alerta)
+	h.notifyOwner(rec, aviso{
+		subject: "🚨 ALERTA CRÍTICA - EasyGrow",
+		titulo:  "🚨 <b>ALERTA CRÍTICA - SENSOR</b>",
+		pie:     pieCritico,
+	})
+}
+
+// resolve cierra las alertas del sensor, publica cada transición y avisa
+// por los mismos canales que la alerta
+func (h *sensorHandler) resolve(rec *sensorRecord) {
+	resueltas, err := resolveAlerts(h.deps.DB, rec.MacAddress, rec.Nombre, tipoAlertaFor(rec.Nombre))
+	if err != nil {
+		log.Printf("   ❌ Error resolviendo alertas de %s: %v", rec.Nombre, err)
+	}
+	if len(resueltas) == 0 {
+		return
+	}
+	log.Printf("   ✅ %s volvió al rango normal (%.2f)", rec.Nombre, rec.Valor)
+
+	for _, ref := range resueltas {
+		publishTransition(h.deps.Hub, rec.ownerID, ref, estadoResuelta, 0)
+	}
+	h.notifyOwner(rec, aviso{
+		subject: "✅ Alerta resuelta - EasyGrow",
+		titulo:  "✅ <b>ALERTA RESUELTA - SENSOR</b>",
+		pie:     "🌱 El valor volvió al rango normal",
+	})
+}
+
+const pieCritico = "🔧 Revisa tu sistema EasyGrow inmediatamente"
+
+// aviso es el texto variable de las notificaciones de un sensor
+type aviso struct {
+	subject string
+	titulo  string
+	extra   string // líneas opcionales antes de la fecha
+	pie     string
+}
+
+// notifyOwner avisa al dueño del dispositivo por los canales de ALERT_CHANNELS
+func (h *sensorHandler) notifyOwner(rec *sensorRecord, a aviso) {
+	to, err := recipientByMac(h.deps.DB, rec.MacAddress)
+	if err != nil {
+		log.Printf("   ❌ Error obteniendo usuario: %v", err)
+		return
+	}
+	log.Printf("   👤 Usuario: %s, Tel: %s", to.Email, to.Phone)
+
+	alertMsg := fmt.Sprintf(`%s
+📍 <b>Dispositivo:</b> %s
+📊 <b>Sensor:</b> %s
+⚠️ <b>Valor:</b> %.2f
+%s🕐 <b>Fecha:</b> %s
+
+%s`,
+		a.titulo, rec.MacAddress, rec.Nombre, rec.Valor, a.extra,
+		rec.leida.In(clock().loc).Format("2006-01-02 15:04:05"), a.pie)
+
+	notify(h.deps.Notifiers.Critical, to, alerts.Message{
+		Subject: a.subject,
+		Text:    alertMsg,
+	})
+}
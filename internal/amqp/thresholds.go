@@ -0,0 +1,236 @@
+package amqp
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"WEBSOCKER_EASYGROW/utils"
+)
+
+const defaultThresholdsReload = time.Minute
+
+// Calidad del dato según los umbrales
+const (
+	calidadBueno       = "bueno"
+	calidadAdvertencia = "advertencia"
+	calidadCritico     = "critico"
+)
+
+// umbral son los límites de advertencia y críticos; un límite nulo no se
+// evalúa. La regla de histéresis es opcional y pisa la del tipo de sensor.
+type umbral struct {
+	advMin, advMax   sql.NullFloat64
+	critMin, critMax sql.NullFloat64
+
+	muestras    sql.NullInt64
+	duracionSeg sql.NullInt64
+	margen      sql.NullFloat64
+}
+
+func limite(v float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v, Valid: true}
+}
+
+// Límites por defecto por tipo de sensor (los mismos de isCritical e
+// isWarning). Lluvia y vibración no son rangos y siguen en esas funciones.
+var umbralesPorDefecto = map[string]umbral{
+	sensorTemperatura:  {advMin: limite(10), advMax: limite(30), critMin: limite(5), critMax: limite(35)},
+	sensorHumedad:      {advMin: limite(40), advMax: limite(80), critMin: limite(30), critMax: limite(90)},
+	sensorLuminosidad:  {advMin: limite(100), critMin: limite(50)},
+	sensorNivelAgua:    {advMin: limite(10), critMin: limite(5)},
+	sensorHumedadSuelo: {advMax: limite(2000), critMax: limite(3000)},
+}
+
+func outside(v float64, min, max sql.NullFloat64) bool {
+	return (min.Valid && v < min.Float64) || (max.Valid && v > max.Float64)
+}
+
+func (u umbral) classify(v float64) string {
+	switch {
+	case outside(v, u.critMin, u.critMax):
+		return calidadCritico
+	case outside(v, u.advMin, u.advMax):
+		return calidadAdvertencia
+	}
+	return calidadBueno
+}
+
+type plantaTipo struct {
+	idPlanta int64
+	tipo     string
+}
+
+type especieTipo struct {
+	especie string
+	tipo    string
+}
+
+// thresholdSet es una foto de umbrales_alerta; se reemplaza entera al recargar
+type thresholdSet struct {
+	porSensor  map[int]umbral
+	porPlanta  map[plantaTipo]umbral
+	porEspecie map[especieTipo]umbral
+	especies   map[int64]string // id_planta → especie
+}
+
+// lookup busca del más específico al más general: sensor, planta, especie
+func (s *thresholdSet) lookup(sensorID int, plantaID sql.NullInt64, tipo string) (umbral, bool) {
+	if u, ok := s.porSensor[sensorID]; ok {
+		return u, true
+	}
+	if !plantaID.Valid || tipo == "" {
+		return umbral{}, false
+	}
+	if u, ok := s.porPlanta[plantaTipo{plantaID.Int64, tipo}]; ok {
+		return u, true
+	}
+	if especie, ok := s.especies[plantaID.Int64]; ok {
+		if u, ok := s.porEspecie[especieTipo{especie, tipo}]; ok {
+			return u, true
+		}
+	}
+	return umbral{}, false
+}
+
+// thresholdStore mantiene los umbrales en memoria y los recarga cada
+// THRESHOLDS_RELOAD (o al pedirlo por /admin o por el exchange de control)
+type thresholdStore struct {
+	db  *sql.DB
+	set atomic.Pointer[thresholdSet]
+}
+
+var thresholdsMain atomic.Pointer[thresholdStore]
+
+func newThresholdStore(db *sql.DB) *thresholdStore {
+	s := &thresholdStore{db: db}
+	if err := s.load(); err != nil {
+		log.Printf("⚠️ No se pudieron cargar umbrales, se usan los valores por defecto: %v", err)
+	}
+	thresholdsMain.Store(s)
+
+	interval := utils.EnvDuration("THRESHOLDS_RELOAD", defaultThresholdsReload)
+	go func() {
+		for range time.Tick(interval) {
+			if err := s.load(); err != nil {
+				log.Printf("⚠️ Error recargando umbrales, se mantienen los anteriores: %v", err)
+			}
+		}
+	}()
+	return s
+}
+
+func (s *thresholdStore) load() error {
+	rows, err := s.db.Query(`
+		SELECT id_sensor, id_planta, especie, COALESCE(tipo_sensor, ''),
+		       advertencia_min, advertencia_max, critico_min, critico_max,
+		       muestras, duracion_seg, margen_recuperacion
+		FROM umbrales_alerta
+		WHERE activo = 1
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	set := &thresholdSet{
+		porSensor:  make(map[int]umbral),
+		porPlanta:  make(map[plantaTipo]umbral),
+		porEspecie: make(map[especieTipo]umbral),
+		especies:   make(map[int64]string),
+	}
+	for rows.Next() {
+		var idSensor, idPlanta sql.NullInt64
+		var especie sql.NullString
+		var tipo string
+		var u umbral
+		if err := rows.Scan(&idSensor, &idPlanta, &especie, &tipo,
+			&u.advMin, &u.advMax, &u.critMin, &u.critMax,
+			&u.muestras, &u.duracionSeg, &u.margen); err != nil {
+			return err
+		}
+		switch {
+		case idSensor.Valid:
+			set.porSensor[int(idSensor.Int64)] = u
+		case idPlanta.Valid && tipo != "":
+			set.porPlanta[plantaTipo{idPlanta.Int64, tipo}] = u
+		case especie.Valid && tipo != "":
+			set.porEspecie[especieTipo{strings.ToLower(especie.String), tipo}] = u
+		default:
+			log.Printf("⚠️ Umbral ignorado: falta id_sensor, o tipo_sensor con id_planta/especie")
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(set.porEspecie) > 0 {
+		if err := s.loadEspecies(set); err != nil {
+			return fmt.Errorf("error leyendo especies de planta: %w", err)
+		}
+	}
+
+	s.set.Store(set)
+	log.Printf("🎚️ Umbrales cargados: %d por sensor, %d por planta, %d por especie",
+		len(set.porSensor), len(set.porPlanta), len(set.porEspecie))
+	return nil
+}
+
+func (s *thresholdStore) loadEspecies(set *thresholdSet) error {
+	rows, err := s.db.Query(`SELECT id_planta, especie FROM planta WHERE activa = 1 AND especie IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var especie string
+		if err := rows.Scan(&id, &especie); err != nil {
+			return err
+		}
+		set.especies[id] = strings.ToLower(especie)
+	}
+	return rows.Err()
+}
+
+// umbralFor devuelve el umbral más específico que haya en la BD o, si no
+// hay ninguno, el del tipo de sensor; false si el sensor no tiene rango
+func umbralFor(sensorID int, plantaID sql.NullInt64, nombre string) (umbral, bool) {
+	tipo := tipoSensor(nombre)
+	if store := thresholdsMain.Load(); store != nil {
+		if set := store.set.Load(); set != nil {
+			if u, ok := set.lookup(sensorID, plantaID, tipo); ok {
+				return u, true
+			}
+		}
+	}
+	u, ok := umbralesPorDefecto[tipo]
+	return u, ok
+}
+
+// classifyReading decide la calidad del dato con el umbral que corresponda
+func classifyReading(sensorID int, plantaID sql.NullInt64, nombre string, valor float64) string {
+	if u, ok := umbralFor(sensorID, plantaID, nombre); ok {
+		return u.classify(valor)
+	}
+
+	switch {
+	case isCritical(nombre, valor):
+		return calidadCritico
+	case isWarning(nombre, valor):
+		return calidadAdvertencia
+	}
+	return calidadBueno
+}
+
+// ReloadThresholds vuelve a leer umbrales_alerta en esta instancia
+func ReloadThresholds() error {
+	store := thresholdsMain.Load()
+	if store == nil {
+		return fmt.Errorf("umbrales no disponibles todavía")
+	}
+	return store.load()
+}
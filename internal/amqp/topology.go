@@ -0,0 +1,276 @@
+package amqp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Topology es la topología que el consumidor declara al conectarse.
+// Se carga de AMQP_TOPOLOGY_FILE (JSON); ver topology.example.json.
+type Topology struct {
+	Exchanges []ExchangeSpec `json:"exchanges"`
+	Queues    []QueueSpec    `json:"queues"`
+	Bindings  []BindingSpec  `json:"bindings"`
+}
+
+type ExchangeSpec struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Durable    bool   `json:"durable"`
+	AutoDelete bool   `json:"auto_delete,omitempty"`
+}
+
+type QueueSpec struct {
+	Name                 string `json:"name"`
+	Durable              bool   `json:"durable"`
+	MessageTTLMs         int64  `json:"message_ttl_ms,omitempty"`
+	MaxLength            int64  `json:"max_length,omitempty"`
+	DeadLetterExchange   string `json:"dead_letter_exchange,omitempty"`
+	DeadLetterRoutingKey string `json:"dead_letter_routing_key,omitempty"`
+}
+
+type BindingSpec struct {
+	Queue      string `json:"queue"`
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routing_key"`
+}
+
+func (q QueueSpec) arguments() amqp.Table {
+	args := amqp.Table{}
+	if q.MessageTTLMs > 0 {
+		args["x-message-ttl"] = q.MessageTTLMs
+	}
+	if q.MaxLength > 0 {
+		args["x-max-length"] = q.MaxLength
+	}
+	if q.DeadLetterExchange != "" {
+		args["x-dead-letter-exchange"] = q.DeadLetterExchange
+	}
+	if q.DeadLetterRoutingKey != "" {
+		args["x-dead-letter-routing-key"] = q.DeadLetterRoutingKey
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return args
+}
+
+// loadTopology lee AMQP_TOPOLOGY_FILE. Sin archivo se usa la topología
+// mínima: el exchange de dead-letter, una cola "<cola>.dlq" por cada
+// cola consumida, que se asume ya existente, y la cola de comandos de bomba.
+func loadTopology(queues ...string) (*Topology, error) {
+	path := os.Getenv("AMQP_TOPOLOGY_FILE")
+	if path == "" {
+		return defaultTopology(queues...), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo topología %s: %w", path, err)
+	}
+	var topo Topology
+	if err := json.Unmarshal(data, &topo); err != nil {
+		return nil, fmt.Errorf("error parseando topología %s: %w", path, err)
+	}
+	if err := topo.validate(); err != nil {
+		return nil, fmt.Errorf("topología %s inválida: %w", path, err)
+	}
+	log.Printf("📐 Topología cargada de %s: %d exchanges, %d colas, %d bindings",
+		path, len(topo.Exchanges), len(topo.Queues), len(topo.Bindings))
+	return &topo, nil
+}
+
+func defaultTopology(queues ...string) *Topology {
+	exchange := deadLetterExchange()
+	topo := &Topology{
+		Exchanges: []ExchangeSpec{{Name: exchange, Type: "direct", Durable: true}},
+		// pump_action publica aquí por el exchange por defecto; si la cola
+		// no existe el comando se pierde sin error
+		Queues: []QueueSpec{{Name: commandQueue(), Durable: true, MessageTTLMs: int64(commandTTL / time.Millisecond)}},
+	}
+	for _, queue := range queues {
+		dlq := queue + ".dlq"
+		topo.Queues = append(topo.Queues, QueueSpec{Name: dlq, Durable: true})
+		topo.Bindings = append(topo.Bindings, BindingSpec{Queue: dlq, Exchange: exchange, RoutingKey: queue})
+	}
+	return topo
+}
+
+func (t *Topology) validate() error {
+	dlx := false
+	for _, ex := range t.Exchanges {
+		if ex.Name == "" {
+			return errors.New("exchange sin nombre")
+		}
+		switch ex.Type {
+		case "direct", "fanout", "topic", "headers":
+		default:
+			return fmt.Errorf("exchange %s: tipo %q no soportado", ex.Name, ex.Type)
+		}
+		dlx = dlx || ex.Name == deadLetterExchange()
+	}
+	// Sin el exchange de dead-letter el primer mensaje veneno no se puede
+	// publicar y vuelve a la cola una y otra vez
+	if !dlx {
+		return fmt.Errorf("falta el exchange de dead-letter %s (DLX_EXCHANGE)", deadLetterExchange())
+	}
+	for _, q := range t.Queues {
+		if q.Name == "" {
+			return errors.New("cola sin nombre")
+		}
+		if q.MessageTTLMs < 0 || q.MaxLength < 0 {
+			return fmt.Errorf("cola %s: message_ttl_ms y max_length no pueden ser negativos", q.Name)
+		}
+	}
+	for _, b := range t.Bindings {
+		if b.Queue == "" || b.Exchange == "" {
+			return fmt.Errorf("binding incompleto: %+v", b)
+		}
+	}
+	return nil
+}
+
+// declare crea o verifica exchanges, colas y bindings en ese orden
+func (t *Topology) declare(ch *amqp.Channel) error {
+	for _, ex := range t.Exchanges {
+		if err := ch.ExchangeDeclare(ex.Name, ex.Type, ex.Durable, ex.AutoDelete, false, false, nil); err != nil {
+			return fmt.Errorf("error declarando exchange %s: %w", ex.Name, err)
+		}
+	}
+	for _, q := range t.Queues {
+		if _, err := ch.QueueDeclare(q.Name, q.Durable, false, false, false, q.arguments()); err != nil {
+			return fmt.Errorf("error declarando cola %s: %w", q.Name, err)
+		}
+	}
+	for _, b := range t.Bindings {
+		if err := ch.QueueBind(b.Queue, b.RoutingKey, b.Exchange, false, nil); err != nil {
+			return fmt.Errorf("error ligando cola %s a %s: %w", b.Queue, b.Exchange, err)
+		}
+	}
+	return nil
+}
+
+// Drift es una diferencia entre la topología deseada y el broker
+type Drift struct {
+	Tipo    string // "exchange", "cola" o "binding"
+	Nombre  string
+	Detalle string
+}
+
+// diff compara la topología con lo que tiene el broker sin crear nada.
+// Cada verificación usa un canal propio porque un error lo cierra. Para
+// ver si los argumentos difieren se redeclara con los mismos parámetros
+// solo lo que ya existe: si coincide es un no-op, si no el broker responde 406.
+func (t *Topology) diff(conn *amqp.Connection) ([]Drift, error) {
+	var drifts []Drift
+
+	check := func(passive, active func(*amqp.Channel) error) (string, error) {
+		ch, err := conn.Channel()
+		if err != nil {
+			return "", err
+		}
+		if err := passive(ch); err != nil {
+			var amqpErr *amqp.Error
+			if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+				return "no existe", nil
+			}
+			return "", err
+		}
+		ch.Close()
+
+		ch, err = conn.Channel()
+		if err != nil {
+			return "", err
+		}
+		defer ch.Close()
+		if err := active(ch); err != nil {
+			var amqpErr *amqp.Error
+			if errors.As(err, &amqpErr) && amqpErr.Code == amqp.PreconditionFailed {
+				return "existe con otra configuración: " + amqpErr.Reason, nil
+			}
+			return "", err
+		}
+		return "", nil
+	}
+
+	for _, ex := range t.Exchanges {
+		ex := ex
+		detalle, err := check(
+			func(ch *amqp.Channel) error {
+				return ch.ExchangeDeclarePassive(ex.Name, ex.Type, ex.Durable, ex.AutoDelete, false, false, nil)
+			},
+			func(ch *amqp.Channel) error {
+				return ch.ExchangeDeclare(ex.Name, ex.Type, ex.Durable, ex.AutoDelete, false, false, nil)
+			})
+		if err != nil {
+			return nil, err
+		}
+		if detalle != "" {
+			drifts = append(drifts, Drift{Tipo: "exchange", Nombre: ex.Name, Detalle: detalle})
+		}
+	}
+
+	for _, q := range t.Queues {
+		q := q
+		detalle, err := check(
+			func(ch *amqp.Channel) error {
+				_, err := ch.QueueDeclarePassive(q.Name, q.Durable, false, false, false, nil)
+				return err
+			},
+			func(ch *amqp.Channel) error {
+				_, err := ch.QueueDeclare(q.Name, q.Durable, false, false, false, q.arguments())
+				return err
+			})
+		if err != nil {
+			return nil, err
+		}
+		if detalle != "" {
+			drifts = append(drifts, Drift{Tipo: "cola", Nombre: q.Name, Detalle: detalle})
+		}
+	}
+
+	return drifts, nil
+}
+
+// TopologyDryRun compara la topología configurada con el broker y solo
+// reporta las diferencias. Devuelve el código de salida: 0 sin
+// diferencias, 1 con diferencias y 2 si no se pudo verificar.
+func TopologyDryRun() int {
+	topo, err := loadTopology(Queues()...)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return 2
+	}
+
+	conn, err := amqp.Dial(os.Getenv("AMQP_URL"))
+	if err != nil {
+		log.Printf("❌ No se pudo conectar a RabbitMQ: %v", err)
+		return 2
+	}
+	defer conn.Close()
+
+	drifts, err := topo.diff(conn)
+	if err != nil {
+		log.Printf("❌ Error comparando topología: %v", err)
+		return 2
+	}
+
+	log.Println("📐 Dry-run de topología AMQP (no se declaró nada)")
+	for _, d := range drifts {
+		log.Printf("   ⚠️ %s %s: %s", d.Tipo, d.Nombre, d.Detalle)
+	}
+	// AMQP 0-9-1 no permite consultar bindings sin crearlos
+	log.Printf("   ℹ️ %d bindings no verificables sin la API de administración", len(topo.Bindings))
+	if len(drifts) > 0 {
+		log.Printf("❌ %d diferencias con el broker", len(drifts))
+		return 1
+	}
+	log.Println("✅ El broker coincide con la topología")
+	return 0
+}
@@ -0,0 +1,117 @@
+package amqp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+var macPattern = regexp.MustCompile(`^([0-9A-Fa-f]{2}[:-]){5}[0-9A-Fa-f]{2}$`)
+
+// Tipos de sensor según el nombre que manda el firmware
+const (
+	sensorTemperatura  = "temperatura"
+	sensorHumedad      = "humedad"
+	sensorLuminosidad  = "luminosidad"
+	sensorNivelAgua    = "nivel_agua"
+	sensorLluvia       = "lluvia"
+	sensorVibracion    = "vibracion"
+	sensorHumedadSuelo = "humedad_suelo"
+)
+
+// tipoSensor clasifica el sensor por su nombre; "" si no se reconoce
+func tipoSensor(nombre string) string {
+	s := strings.ToLower(nombre)
+	switch {
+	case strings.Contains(s, "temperatura"):
+		return sensorTemperatura
+	case strings.Contains(s, "yl-69") || (strings.Contains(s, "humedad") && strings.Contains(s, "suelo")):
+		return sensorHumedadSuelo
+	case strings.Contains(s, "humedad"):
+		return sensorHumedad
+	case strings.Contains(s, "luminosidad"):
+		return sensorLuminosidad
+	case strings.Contains(s, "ultrasonico"):
+		return sensorNivelAgua
+	case strings.Contains(s, "lluvia") || strings.Contains(s, "yl-83"):
+		return sensorLluvia
+	case strings.Contains(s, "vibracion") || strings.Contains(s, "sw-420"):
+		return sensorVibracion
+	}
+	return ""
+}
+
+// Rango físico que puede reportar cada tipo de sensor; fuera de él la
+// lectura es basura del firmware, no un valor crítico
+var rangosSensor = map[string]struct{ min, max float64 }{
+	sensorTemperatura:  {-40, 85},   // DHT22 / DS18B20, °C
+	sensorHumedad:      {0, 100},    // %
+	sensorLuminosidad:  {0, 100000}, // lux
+	sensorNivelAgua:    {0, 400},    // HC-SR04, cm
+	sensorLluvia:       {0, 4095},   // digital o ADC de 12 bits
+	sensorVibracion:    {0, 1},      // SW-420, digital
+	sensorHumedadSuelo: {0, 4095},   // YL-69, ADC de 12 bits
+}
+
+const (
+	maxNombreSensor    = 100
+	maxEventoBomba     = 255
+	maxTiempoEncendida = 24 * 60 * 60
+	maxADC             = 4095
+)
+
+func validateMac(mac string) error {
+	if mac == "" {
+		return errors.New("mac_address es obligatorio")
+	}
+	if !macPattern.MatchString(mac) {
+		return fmt.Errorf("mac_address %q no tiene formato AA:BB:CC:DD:EE:FF", mac)
+	}
+	return nil
+}
+
+func validateSensorData(d SensorData) error {
+	if err := validateMac(d.MacAddress); err != nil {
+		return err
+	}
+	if strings.TrimSpace(d.Nombre) == "" {
+		return errors.New("nombre es obligatorio")
+	}
+	if len(d.Nombre) > maxNombreSensor {
+		return fmt.Errorf("nombre supera %d caracteres", maxNombreSensor)
+	}
+	if math.IsNaN(d.Valor) || math.IsInf(d.Valor, 0) {
+		return fmt.Errorf("valor no es un número finito: %v", d.Valor)
+	}
+	if r, ok := rangosSensor[tipoSensor(d.Nombre)]; ok && (d.Valor < r.min || d.Valor > r.max) {
+		return fmt.Errorf("valor %.2f fuera de rango [%g, %g] para %s", d.Valor, r.min, r.max, d.Nombre)
+	}
+	return nil
+}
+
+func validateBombaEvent(e BombaEvent) error {
+	if err := validateMac(e.MacAddress); err != nil {
+		return err
+	}
+	if strings.TrimSpace(e.Evento) == "" {
+		return errors.New("evento es obligatorio")
+	}
+	if len(e.Evento) > maxEventoBomba {
+		return fmt.Errorf("evento supera %d caracteres", maxEventoBomba)
+	}
+	if e.Bomba != "" && e.Bomba != "A" && e.Bomba != "B" {
+		return fmt.Errorf("bomba %q inválida, se espera A o B", e.Bomba)
+	}
+	if e.IDSensor < 0 {
+		return fmt.Errorf("id_sensor inválido: %d", e.IDSensor)
+	}
+	if math.IsNaN(e.ValorHumedad) || e.ValorHumedad < 0 || e.ValorHumedad > maxADC {
+		return fmt.Errorf("valor_humedad %v fuera de rango [0, %d]", e.ValorHumedad, maxADC)
+	}
+	if t := e.TiempoEncendidaSeg; t != nil && (*t < 0 || *t > maxTiempoEncendida) {
+		return fmt.Errorf("tiempo_encendida_seg %d fuera de rango [0, %d]", *t, maxTiempoEncendida)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package amqp
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strings"
+	"time"
+
+	"WEBSOCKER_EASYGROW/utils"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	defaultPrefetch = 50
+	defaultWorkers  = 4
+)
+
+// queueOptions controla la concurrencia de una cola
+type queueOptions struct {
+	prefetch int
+	workers  int
+}
+
+// queueOptionsFor lee AMQP_PREFETCH y AMQP_WORKERS, que se pueden
+// sobreescribir por cola con el nombre en mayúsculas como sufijo
+// (ej. AMQP_WORKERS_DATOS_SENSORES=8)
+func queueOptionsFor(queue string) queueOptions {
+	suffix := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(queue))
+
+	opts := queueOptions{
+		prefetch: utils.EnvPositiveInt("AMQP_PREFETCH", defaultPrefetch),
+		workers:  utils.EnvPositiveInt("AMQP_WORKERS", defaultWorkers),
+	}
+	opts.prefetch = utils.EnvPositiveInt("AMQP_PREFETCH_"+suffix, opts.prefetch)
+	opts.workers = utils.EnvPositiveInt("AMQP_WORKERS_"+suffix, opts.workers)
+	return opts
+}
+
+type job struct {
+	msg amqp.Delivery
+	h   Handler
+	rec interface{}
+	key string // clave de idempotencia reservada en la ventana de dedupe ("" si no se deduplica)
+}
+
+// consumeQueue empieza a consumir la cola con confirmación manual. Un
+// dispatcher decodifica cada mensaje y lo manda al worker que le toca
+// según el hash de su MAC, así los eventos de un mismo dispositivo se
+// procesan en orden mientras distintos dispositivos avanzan en paralelo.
+func consumeQueue(ch *amqp.Channel, queueName string, registry *Registry) error {
+	opts := queueOptionsFor(queueName)
+	if err := ch.Qos(opts.prefetch, 0, false); err != nil {
+		return fmt.Errorf("error configurando prefetch de %s: %w", queueName, err)
+	}
+
+	msgs, err := ch.Consume(queueName, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("error al consumir cola %s: %w", queueName, err)
+	}
+
+	shards := make([]chan job, opts.workers)
+	for i := range shards {
+		shards[i] = make(chan job, opts.prefetch/opts.workers+1)
+		go worker(ch, queueName, shards[i])
+	}
+
+	log.Printf("🔄 Consumiendo mensajes de cola: %s (prefetch %d, workers %d)",
+		queueName, opts.prefetch, opts.workers)
+	go func() {
+		// El loop termina cuando se cierra el canal; el supervisor lo vuelve a lanzar
+		for msg := range msgs {
+			h, ok := registry.lookup(queueName, msg.RoutingKey)
+			if !ok {
+				settle(ch, queueName, msg, poison("sin_handler",
+					fmt.Errorf("no hay handler para cola %s, routing key %q", queueName, msg.RoutingKey)))
+				continue
+			}
+
+			log.Printf("📥 %s RECIBIDO:", strings.ToUpper(h.Name()))
+			log.Printf("   📋 Raw Data: %s", string(msg.Body))
+			log.Printf("   🕐 Timestamp: %s", time.Now().Format("2006-01-02 15:04:05"))
+
+			rec, err := decode(h, msg.Body)
+			if err != nil {
+				quarantineOrSettle(ch, queueName, msg, err)
+				continue
+			}
+
+			// El firmware reintenta los publish: un duplicado se confirma sin
+			// guardar, publicar ni alertar
+			key := idempotencyKey(queueName, msg, rec)
+			if key != "" && !dedupe().reserve(key) {
+				log.Printf("   ♻️ Duplicado descartado (%s)", key)
+				if err := msg.Ack(false); err != nil {
+					log.Printf("   ❌ Error confirmando mensaje: %v", err)
+				}
+				continue
+			}
+			shards[shardFor(rec, len(shards))] <- job{msg: msg, h: h, rec: rec, key: key}
+		}
+		for _, jobs := range shards {
+			close(jobs)
+		}
+		log.Printf("🔌 Consumidor de %s detenido", queueName)
+	}()
+	return nil
+}
+
+// inFlight es un job cuyo guardado asíncrono todavía no terminó
+type inFlight struct {
+	job    job
+	result chan error
+}
+
+// worker procesa los jobs de su shard en orden. Los handlers que guardan
+// en lotes no lo frenan: el worker sigue tomando jobs mientras el lote se
+// junta, y cuando cada guardado termina publica, alerta y confirma desde
+// aquí, en el orden en que llegaron, para no cargar todo eso sobre el
+// goroutine del lote.
+func worker(ch *amqp.Channel, queueName string, jobs <-chan job) {
+	var pending []inFlight
+	complete := func(err error) {
+		f := pending[0]
+		pending = pending[1:]
+		finishJob(ch, queueName, f.job, afterPersist(f.job.h, f.job.rec, err))
+		log.Println("   " + strings.Repeat("-", 58))
+	}
+
+	for jobs != nil || len(pending) > 0 {
+		var next <-chan error
+		if len(pending) > 0 {
+			next = pending[0].result
+		}
+
+		select {
+		case err := <-next:
+			complete(err)
+		case j, ok := <-jobs:
+			if !ok {
+				jobs = nil
+				continue
+			}
+			if ap, ok := j.h.(asyncPersister); ok {
+				result := make(chan error, 1)
+				ap.PersistAsync(j.rec, func(err error) { result <- err })
+				pending = append(pending, inFlight{job: j, result: result})
+				continue
+			}
+			// Un handler síncrono espera a los anteriores para respetar el orden
+			for len(pending) > 0 {
+				complete(<-pending[0].result)
+			}
+			finishJob(ch, queueName, j, process(j.h, j.rec))
+			log.Println("   " + strings.Repeat("-", 58))
+		}
+	}
+}
+
+// finishJob libera la clave de idempotencia si el mensaje no se guardó,
+// para que su reentrega no se tome como duplicado, y lo confirma
+func finishJob(ch *amqp.Channel, queueName string, j job, err error) {
+	if err != nil && j.key != "" {
+		dedupe().release(j.key)
+	}
+	settle(ch, queueName, j.msg, err)
+}
+
+func shardFor(rec interface{}, n int) int {
+	key := ""
+	if k, ok := rec.(shardKeyer); ok {
+		key = k.shardKey()
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(n))
+}
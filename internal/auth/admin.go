@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var ErrNoAdminToken = errors.New("ADMIN_TOKEN no configurado")
+
+// VerifyAdmin comprueba el token de administración, que llega en el
+// header X-Admin-Token o como "Authorization: Bearer ...". Sin
+// ADMIN_TOKEN los endpoints de administración quedan deshabilitados.
+func VerifyAdmin(r *http.Request) error {
+	expected := os.Getenv("ADMIN_TOKEN")
+	if expected == "" {
+		return ErrNoAdminToken
+	}
+
+	// No se acepta ?token= para que el token no quede en logs de acceso
+	token := r.Header.Get("X-Admin-Token")
+	if h := r.Header.Get("Authorization"); token == "" && len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		token = strings.TrimSpace(h[7:])
+	}
+	if token == "" {
+		return ErrMissingToken
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return ErrInvalidToken
+	}
+	return nil
+}
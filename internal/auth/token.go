@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoSecret     = errors.New("WS_AUTH_SECRET no configurado")
+	ErrMissingToken = errors.New("token no proporcionado")
+	ErrInvalidToken = errors.New("token inválido")
+	ErrExpiredToken = errors.New("token expirado")
+)
+
+// Claims que esperamos en el token (JWT HS256)
+type Claims struct {
+	IDUsuario int    `json:"id_usuario"`
+	Subject   string `json:"sub,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// TokenFromRequest busca el token en "Authorization: Bearer ..." o en ?token=
+func TokenFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+			return strings.TrimSpace(h[7:])
+		}
+	}
+	return r.URL.Query().Get("token")
+}
+
+// VerifyToken valida firma y expiración y devuelve el id_usuario del token
+func VerifyToken(token string) (int, error) {
+	secret := os.Getenv("WS_AUTH_SECRET")
+	if secret == "" {
+		return 0, ErrNoSecret
+	}
+	if token == "" {
+		return 0, ErrMissingToken
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalidToken
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil || h.Alg != "HS256" {
+		return 0, ErrInvalidToken
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return 0, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return 0, ErrInvalidToken
+	}
+	if claims.ExpiresAt != 0 && time.Now().Unix() >= claims.ExpiresAt {
+		return 0, ErrExpiredToken
+	}
+
+	userID := claims.IDUsuario
+	if userID == 0 && claims.Subject != "" {
+		userID, err = strconv.Atoi(claims.Subject)
+		if err != nil {
+			return 0, fmt.Errorf("%w: sub no es un id_usuario", ErrInvalidToken)
+		}
+	}
+	if userID <= 0 {
+		return 0, fmt.Errorf("%w: falta id_usuario", ErrInvalidToken)
+	}
+	return userID, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,111 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+// Backplane reparte los mensajes publicados en cualquier instancia del
+// servicio a los Hubs de todas las instancias. Publish debe entregar
+// también a la propia instancia (a través de la función de Subscribe).
+type Backplane interface {
+	Publish(msg Message) error
+	Subscribe(deliver func(Message)) error
+}
+
+// SetBackplane conecta el Hub a un backplane; a partir de ahí Publish pasa
+// por él. Con nil se vuelve a la entrega local.
+func (h *Hub) SetBackplane(b Backplane) error {
+	if b != nil {
+		if err := b.Subscribe(h.deliver); err != nil {
+			return err
+		}
+	}
+	h.backplaneMu.Lock()
+	h.backplane = b
+	h.backplaneMu.Unlock()
+	return nil
+}
+
+func (h *Hub) getBackplane() Backplane {
+	h.backplaneMu.RLock()
+	defer h.backplaneMu.RUnlock()
+	return h.backplane
+}
+
+// deliver entrega un mensaje a los clientes de esta instancia
+func (h *Hub) deliver(msg Message) {
+	h.broadcast <- msg
+}
+
+// LocalBackplane une varios Hubs dentro del mismo proceso. Sirve para
+// pruebas y para correr una sola instancia con la misma interfaz.
+type LocalBackplane struct {
+	mu   sync.RWMutex
+	subs []func(Message)
+}
+
+func NewLocalBackplane() *LocalBackplane {
+	return &LocalBackplane{}
+}
+
+func (b *LocalBackplane) Publish(msg Message) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, deliver := range b.subs {
+		deliver(msg)
+	}
+	return nil
+}
+
+func (b *LocalBackplane) Subscribe(deliver func(Message)) error {
+	b.mu.Lock()
+	b.subs = append(b.subs, deliver)
+	b.mu.Unlock()
+	return nil
+}
+
+// Formato de un Message en el cable, para backplanes entre procesos
+type wireMessage struct {
+	Tipo       string          `json:"tipo"`
+	MacAddress string          `json:"mac_address,omitempty"`
+	Nombre     string          `json:"nombre,omitempty"`
+	IDUsuario  int             `json:"id_usuario"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// EncodeMessage serializa un Message para enviarlo a otras instancias
+func EncodeMessage(msg Message) ([]byte, error) {
+	payload, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(wireMessage{
+		Tipo:       msg.Tipo,
+		MacAddress: msg.MacAddress,
+		Nombre:     msg.Nombre,
+		IDUsuario:  msg.IDUsuario,
+		Payload:    payload,
+	})
+}
+
+// DecodeMessage reconstruye un Message recibido de otra instancia; el
+// payload queda como JSON crudo
+func DecodeMessage(data []byte) (Message, error) {
+	var w wireMessage
+	if err := json.Unmarshal(data, &w); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Tipo:       w.Tipo,
+		MacAddress: w.MacAddress,
+		Nombre:     w.Nombre,
+		IDUsuario:  w.IDUsuario,
+		Payload:    w.Payload,
+	}, nil
+}
+
+func logBackplaneFallback(err error) {
+	log.Printf("⚠️ Backplane no disponible, entregando solo en esta instancia: %v", err)
+}
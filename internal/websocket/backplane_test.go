@@ -0,0 +1,79 @@
+package websocket
+
+import "testing"
+
+func lectura(mac string, valor float64) Message {
+	return Message{
+		Tipo:       TipoSensor,
+		MacAddress: mac,
+		Nombre:     "Temperatura",
+		IDUsuario:  1,
+		Payload:    map[string]float64{"valor": valor},
+	}
+}
+
+// twoHubs arma dos instancias unidas por un LocalBackplane
+func twoHubs(t *testing.T) (*Hub, *Hub) {
+	t.Helper()
+	bp := NewLocalBackplane()
+	a, b := startHub(t), startHub(t)
+	for _, h := range []*Hub{a, b} {
+		if err := h.SetBackplane(bp); err != nil {
+			t.Fatalf("SetBackplane: %v", err)
+		}
+	}
+	return a, b
+}
+
+// publishEverywhere publica en from y espera a que todas las instancias lo entreguen
+func publishEverywhere(t *testing.T, from *Hub, hubs []*Hub, msg Message) {
+	t.Helper()
+	before := make([]int64, len(hubs))
+	for i, h := range hubs {
+		before[i] = h.metrics.publicados.Load()
+	}
+	from.Publish(msg)
+	for i, h := range hubs {
+		h := h
+		waitFor(t, func() bool { return h.metrics.publicados.Load() > before[i] })
+	}
+}
+
+func TestLocalBackplaneDeliversToOtherHub(t *testing.T) {
+	a, b := twoHubs(t)
+
+	c := newClient(b, "test", 1)
+	b.Register(c)
+	subscribe(t, b, c, Subscription{MacAddress: macA})
+
+	publishEverywhere(t, a, []*Hub{a, b}, lectura(macA, 21.5))
+
+	envs := drain(t, c)
+	if len(envs) != 1 || envs[0].Type != TipoSensor {
+		t.Fatalf("envelopes en B = %+v, se esperaba una lectura", envs)
+	}
+	if envs[0].Epoch != b.epoch {
+		t.Fatalf("epoch = %q, se esperaba el de B (%q)", envs[0].Epoch, b.epoch)
+	}
+}
+
+func TestResumeFromOtherEpochSendsSnapshot(t *testing.T) {
+	a, b := twoHubs(t)
+	if a.epoch == b.epoch {
+		t.Fatal("dos Hubs con el mismo epoch")
+	}
+
+	publishEverywhere(t, a, []*Hub{a, b}, lectura(macA, 20))
+	publishEverywhere(t, a, []*Hub{a, b}, lectura(macA, 22))
+
+	// El cliente vio el seq 1 en A y se reconecta a B, que también tiene seq 2
+	c := newClient(b, "test", 1)
+	c.resumeFrom = resumeToken{epoch: a.epoch, seq: 1}
+	b.Register(c)
+	subscribe(t, b, c, Subscription{MacAddress: macA})
+
+	envs := drain(t, c)
+	if len(envs) != 1 || envs[0].Type != TipoSnapshot {
+		t.Fatalf("envelopes en B = %+v, se esperaba un snapshot", envs)
+	}
+}
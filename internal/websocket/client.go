@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// Políticas de desbordamiento del buffer de envío de cada cliente
+type OverflowPolicy string
+
+const (
+	DropOldest OverflowPolicy = "drop_oldest" // descartar el mensaje más antiguo en cola
+	DropNewest OverflowPolicy = "drop_newest" // descartar el mensaje que llega
+	Disconnect OverflowPolicy = "disconnect"  // cerrar la conexión del cliente lento
+)
+
+const defaultSendBuffer = 256
+
+// Client es un suscriptor del Hub con su propio buffer de salida. No sabe
+// nada del transporte: WebSocket y SSE drenan el canal send cada uno en
+// su propia goroutine de escritura.
+type Client struct {
+	hub    *Hub
+	send   chan frame
+	remote string
+
+	// Usuario autenticado en el handshake
+	userID int
+	// Último seq (y su epoch) que el cliente dice haber recibido antes de reconectarse
+	resumeFrom resumeToken
+
+	// Suscripciones activas; solo las modifica Hub.Run
+	subs map[Subscription]bool
+}
+
+func newClient(hub *Hub, remote string, userID int) *Client {
+	return &Client{
+		hub:    hub,
+		send:   make(chan frame, hub.sendBuffer),
+		remote: remote,
+		userID: userID,
+		subs:   make(map[Subscription]bool),
+	}
+}
+
+// wants indica si el mensaje es de un dispositivo del usuario y alguna
+// suscripción del cliente lo acepta
+func (c *Client) wants(m Message) bool {
+	if m.IDUsuario != c.userID {
+		return false
+	}
+	for sub := range c.subs {
+		if sub.matches(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueue intenta dejar el frame en el buffer del cliente sin bloquear.
+// Devuelve false si el cliente debe ser desconectado.
+func (c *Client) enqueue(f frame, policy OverflowPolicy) bool {
+	select {
+	case c.send <- f:
+		return true
+	default:
+	}
+
+	switch policy {
+	case DropNewest:
+		c.hub.metrics.descartados.Add(1)
+		log.Printf("⚠️ Buffer lleno para %s, descartando mensaje nuevo", c.remote)
+		return true
+	case DropOldest:
+		// Solo Hub.Run encola, así que tras sacar uno siempre hay espacio
+		select {
+		case <-c.send:
+		default:
+		}
+		select {
+		case c.send <- f:
+		default:
+		}
+		c.hub.metrics.descartados.Add(1)
+		log.Printf("⚠️ Buffer lleno para %s, descartando mensaje más antiguo", c.remote)
+		return true
+	default:
+		c.hub.metrics.lentos.Add(1)
+		log.Printf("⚠️ Buffer lleno para %s, desconectando cliente lento", c.remote)
+		return false
+	}
+}
+
+// stop saca al cliente del Hub y vacía el canal hasta que el Hub lo cierre
+func (c *Client) stop() {
+	c.hub.Unregister(c)
+	for range c.send {
+	}
+}
+
+func overflowPolicyFromEnv() OverflowPolicy {
+	v := OverflowPolicy(strings.ToLower(os.Getenv("WS_OVERFLOW_POLICY")))
+	switch v {
+	case DropOldest, DropNewest, Disconnect:
+		return v
+	case "":
+		return DropOldest
+	}
+	log.Printf("⚠️ WS_OVERFLOW_POLICY inválida (%q), usando %s", v, DropOldest)
+	return DropOldest
+}
@@ -0,0 +1,255 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+)
+
+// Códigos de error que se devuelven en los frames de tipo "error"
+const (
+	ErrCodeJSONInvalido       = "json_invalido"
+	ErrCodeComandoDesconocido = "comando_desconocido"
+	ErrCodeParametros         = "parametros_invalidos"
+	ErrCodeNoEncontrado       = "no_encontrado"
+	ErrCodeNoDisponible       = "no_disponible"
+	ErrCodeInterno            = "error_interno"
+)
+
+// ErrNotFound lo devuelven las Actions cuando el recurso no existe o no
+// pertenece al usuario que envió el comando
+var ErrNotFound = errors.New("recurso no encontrado")
+
+// Actions son las operaciones con efectos fuera del Hub (BD, dispositivos).
+// Las implementa el paquete que tiene acceso a ellas y se registran con SetActions.
+type Actions interface {
+	AckAlert(userID int, idAlerta int64) error
+	PumpAction(userID int, action PumpAction) error
+}
+
+// PumpAction es una orden manual para una bomba
+type PumpAction struct {
+	MacAddress  string `json:"mac_address"`
+	Bomba       string `json:"bomba"`
+	Accion      string `json:"accion"`
+	DuracionSeg int    `json:"duracion_seg,omitempty"`
+}
+
+// Command es un frame enviado por el cliente:
+//
+//	{"id": "1", "cmd": "subscribe", "params": {"mac_address": "AA:BB:CC:DD:EE:FF"}}
+type Command struct {
+	ID     string          `json:"id,omitempty"`
+	Cmd    string          `json:"cmd"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type commandReply struct {
+	ID     string      `json:"id,omitempty"`
+	Cmd    string      `json:"cmd"`
+	OK     bool        `json:"ok"`
+	Result interface{} `json:"result,omitempty"`
+}
+
+// CommandError es la respuesta a un comando que no se pudo ejecutar
+type CommandError struct {
+	ID       string   `json:"id,omitempty"`
+	Cmd      string   `json:"cmd,omitempty"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	Detalles []string `json:"detalles,omitempty"`
+}
+
+type commandSpec struct {
+	schema paramsSchema
+	handle func(h *Hub, c *Client, cmd Command) (interface{}, *CommandError)
+}
+
+var subscriptionSchema = paramsSchema{
+	"mac_address": {Type: "string", Pattern: macPattern},
+	"nombre":      {Type: "string", MaxLength: 100},
+	"tipo":        {Type: "string", Enum: []string{TipoSensor, TipoBomba, TipoAlerta, TipoAlertaEstado, TipoCalidad}},
+}
+
+var commands = map[string]commandSpec{
+	"subscribe": {
+		schema: subscriptionSchema,
+		handle: func(h *Hub, c *Client, cmd Command) (interface{}, *CommandError) {
+			return h.changeSubscription(c, cmd, true)
+		},
+	},
+	"unsubscribe": {
+		schema: subscriptionSchema,
+		handle: func(h *Hub, c *Client, cmd Command) (interface{}, *CommandError) {
+			return h.changeSubscription(c, cmd, false)
+		},
+	},
+	"ping": {
+		schema: paramsSchema{},
+		handle: func(h *Hub, c *Client, cmd Command) (interface{}, *CommandError) {
+			return map[string]bool{"pong": true}, nil
+		},
+	},
+	"snapshot": {
+		schema: paramsSchema{
+			"mac_address": {Type: "string", Pattern: macPattern},
+		},
+		handle: func(h *Hub, c *Client, cmd Command) (interface{}, *CommandError) {
+			var filter Subscription
+			json.Unmarshal(cmd.Params, &filter)
+			filter = filter.normalize()
+			if filter.empty() {
+				return h.snapshotFor(c, nil), nil
+			}
+			return h.snapshotFor(c, &filter), nil
+		},
+	},
+	// resume_from y epoch son los del último envelope recibido: un seq sin
+	// su epoch no dice de qué instancia viene
+	"resume": {
+		schema: paramsSchema{
+			"resume_from": {Type: "integer", Required: true},
+			"epoch":       {Type: "string", Required: true, MaxLength: 64},
+		},
+		handle: func(h *Hub, c *Client, cmd Command) (interface{}, *CommandError) {
+			var p struct {
+				ResumeFrom int64  `json:"resume_from"`
+				Epoch      string `json:"epoch"`
+			}
+			json.Unmarshal(cmd.Params, &p)
+			if p.ResumeFrom < 0 {
+				return nil, &CommandError{Code: ErrCodeParametros, Message: "resume_from no puede ser negativo"}
+			}
+			h.do(c, func() {
+				c.resumeFrom = resumeToken{epoch: p.Epoch, seq: uint64(p.ResumeFrom)}
+				subs := make([]Subscription, 0, len(c.subs))
+				for sub := range c.subs {
+					subs = append(subs, sub)
+				}
+				// Sin suscripciones todavía, se aplica a las próximas
+				if len(subs) > 0 {
+					h.catchUp(c, subs, c.resumeFrom, nil)
+				}
+			}, nil)
+			return map[string]int64{"resume_from": p.ResumeFrom}, nil
+		},
+	},
+	"ack_alert": {
+		schema: paramsSchema{
+			"id_alerta": {Type: "integer", Required: true},
+		},
+		handle: func(h *Hub, c *Client, cmd Command) (interface{}, *CommandError) {
+			var p struct {
+				IDAlerta int64 `json:"id_alerta"`
+			}
+			json.Unmarshal(cmd.Params, &p)
+			actions := h.getActions()
+			if actions == nil {
+				return nil, &CommandError{Code: ErrCodeNoDisponible, Message: "acciones no configuradas"}
+			}
+			if err := actions.AckAlert(c.userID, p.IDAlerta); err != nil {
+				return nil, actionError(err)
+			}
+			return map[string]int64{"id_alerta": p.IDAlerta}, nil
+		},
+	},
+	"pump_action": {
+		schema: paramsSchema{
+			"mac_address":  {Type: "string", Required: true, Pattern: macPattern},
+			"bomba":        {Type: "string", Required: true, Enum: []string{"A", "B"}},
+			"accion":       {Type: "string", Required: true, Enum: []string{"encender", "apagar"}},
+			"duracion_seg": {Type: "integer"},
+		},
+		handle: func(h *Hub, c *Client, cmd Command) (interface{}, *CommandError) {
+			var p PumpAction
+			json.Unmarshal(cmd.Params, &p)
+			if p.DuracionSeg < 0 || p.DuracionSeg > 3600 {
+				return nil, &CommandError{Code: ErrCodeParametros, Message: "duracion_seg debe estar entre 0 y 3600"}
+			}
+			actions := h.getActions()
+			if actions == nil {
+				return nil, &CommandError{Code: ErrCodeNoDisponible, Message: "acciones no configuradas"}
+			}
+			if err := actions.PumpAction(c.userID, p); err != nil {
+				return nil, actionError(err)
+			}
+			return p, nil
+		},
+	},
+}
+
+func actionError(err error) *CommandError {
+	if errors.Is(err, ErrNotFound) {
+		return &CommandError{Code: ErrCodeNoEncontrado, Message: err.Error()}
+	}
+	log.Printf("❌ Error ejecutando comando: %v", err)
+	return &CommandError{Code: ErrCodeInterno, Message: "no se pudo ejecutar el comando"}
+}
+
+// handleCommand procesa un frame entrante y responde solo al cliente que lo envió
+func (h *Hub) handleCommand(c *Client, msg []byte) {
+	var cmd Command
+	if err := json.Unmarshal(msg, &cmd); err != nil {
+		h.reply(c, TipoError, CommandError{Code: ErrCodeJSONInvalido, Message: "el frame no es JSON válido"})
+		return
+	}
+
+	spec, ok := commands[cmd.Cmd]
+	if !ok {
+		h.reply(c, TipoError, CommandError{ID: cmd.ID, Cmd: cmd.Cmd, Code: ErrCodeComandoDesconocido, Message: "comando desconocido"})
+		return
+	}
+
+	if problems := spec.schema.validate(cmd.Params); len(problems) > 0 {
+		h.reply(c, TipoError, CommandError{
+			ID: cmd.ID, Cmd: cmd.Cmd, Code: ErrCodeParametros,
+			Message: "parámetros inválidos", Detalles: problems,
+		})
+		return
+	}
+
+	result, cmdErr := spec.handle(h, c, cmd)
+	if cmdErr != nil {
+		cmdErr.ID, cmdErr.Cmd = cmd.ID, cmd.Cmd
+		h.reply(c, TipoError, cmdErr)
+		return
+	}
+	h.reply(c, TipoRespuesta, commandReply{ID: cmd.ID, Cmd: cmd.Cmd, OK: true, Result: result})
+}
+
+func (h *Hub) changeSubscription(c *Client, cmd Command, add bool) (interface{}, *CommandError) {
+	var sub Subscription
+	json.Unmarshal(cmd.Params, &sub)
+	sub = sub.normalize()
+	if sub.empty() {
+		return nil, &CommandError{Code: ErrCodeParametros, Message: "la suscripción necesita mac_address, nombre o tipo"}
+	}
+
+	h.do(c, func() {
+		if !add {
+			delete(c.subs, sub)
+			return
+		}
+		// Si el cliente se está reconectando se le reenvía lo que se perdió;
+		// si no, los últimos valores que cubre la nueva suscripción
+		if c.resumeFrom.seq > 0 {
+			h.resume(c, []Subscription{sub})
+		} else {
+			h.sendSnapshot(c, sub.matches)
+		}
+		c.subs[sub] = true
+	}, nil)
+	return sub, nil
+}
+
+// reply envía un frame solo a este cliente, pasando por Hub.Run
+func (h *Hub) reply(c *Client, tipo string, payload interface{}) {
+	if f, ok := newEnvelope(tipo, 0, payload).frame(); ok {
+		h.do(c, nil, &f)
+	}
+}
+
+func normalizeMac(mac string) string {
+	return strings.ToUpper(strings.TrimSpace(mac))
+}
@@ -0,0 +1,38 @@
+package websocket
+
+import (
+	"log"
+	"time"
+
+	"WEBSOCKER_EASYGROW/utils"
+)
+
+const (
+	defaultPingInterval   = 30 * time.Second
+	defaultPongTimeout    = 60 * time.Second
+	defaultWriteTimeout   = 10 * time.Second
+	defaultMaxMessageSize = 4096
+)
+
+// keepalive agrupa los tiempos y límites de cada conexión
+type keepalive struct {
+	pingInterval   time.Duration
+	pongTimeout    time.Duration
+	writeTimeout   time.Duration
+	maxMessageSize int64
+}
+
+func keepaliveFromEnv() keepalive {
+	k := keepalive{
+		pingInterval:   utils.EnvDuration("WS_PING_INTERVAL", defaultPingInterval),
+		pongTimeout:    utils.EnvDuration("WS_PONG_TIMEOUT", defaultPongTimeout),
+		writeTimeout:   utils.EnvDuration("WS_WRITE_TIMEOUT", defaultWriteTimeout),
+		maxMessageSize: int64(utils.EnvPositiveInt("WS_MAX_MESSAGE_SIZE", defaultMaxMessageSize)),
+	}
+	// El ping tiene que salir antes de que venza la espera del pong
+	if k.pingInterval >= k.pongTimeout {
+		k.pingInterval = k.pongTimeout * 9 / 10
+		log.Printf("⚠️ WS_PING_INTERVAL debe ser menor que WS_PONG_TIMEOUT, usando %s", k.pingInterval)
+	}
+	return k
+}
@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Versión del formato de envelope; subirla solo con cambios incompatibles
+const EnvelopeVersion = 1
+
+// Tipos de mensaje que publica el Hub
+const (
+	TipoSensor       = "sensor_data"
+	TipoBomba        = "bomba_event"
+	TipoAlerta       = "alerta"
+	TipoAlertaEstado = "alerta_estado" // reconocida o resuelta, después de abrirse
+	TipoCalidad      = "calidad_dato"
+	TipoSnapshot     = "snapshot"
+	TipoRespuesta    = "respuesta"
+	TipoError        = "error"
+)
+
+// Message es lo que se publica en el Hub junto con los datos necesarios
+// para decidir a qué clientes enrutarlo. IDUsuario es el dueño del
+// dispositivo: solo sus conexiones reciben el mensaje. Payload se
+// serializa tal cual dentro del envelope.
+type Message struct {
+	Tipo       string
+	MacAddress string
+	Nombre     string
+	IDUsuario  int
+	Payload    interface{}
+}
+
+// Envelope es el formato de todo frame que sale hacia los clientes.
+// Seq solo se asigna a los mensajes del flujo de datos; las respuestas
+// dirigidas a un cliente no lo llevan. Epoch identifica la instancia (y
+// el arranque) que asignó el seq: un seq solo sirve para reanudar junto
+// con su epoch.
+type Envelope struct {
+	Type     string      `json:"type"`
+	Version  int         `json:"version"`
+	Seq      uint64      `json:"seq,omitempty"`
+	Epoch    string      `json:"epoch,omitempty"`
+	ServerTS time.Time   `json:"server_ts"`
+	Payload  interface{} `json:"payload"`
+}
+
+// Payload de un frame de calidad_dato
+type CalidadDato struct {
+	MacAddress string  `json:"mac_address"`
+	Nombre     string  `json:"nombre"`
+	IDSensor   int     `json:"id_sensor"`
+	Valor      float64 `json:"valor"`
+	Calidad    string  `json:"calidad"`
+}
+
+// Payload de un frame de alerta
+type Alerta struct {
+	IDAlerta     int64   `json:"id_alerta"`
+	IDPlanta     int     `json:"id_planta"`
+	TipoAlerta   string  `json:"tipo_alerta"`
+	Nivel        string  `json:"nivel"`
+	Mensaje      string  `json:"mensaje"`
+	MacAddress   string  `json:"mac_address"`
+	Nombre       string  `json:"nombre"`
+	Valor        float64 `json:"valor"`
+	Repeticiones int     `json:"repeticiones,omitempty"`
+	Estado       string  `json:"estado,omitempty"`
+}
+
+// AlertaEstado es una transición del ciclo de vida de una alerta:
+// abierta → reconocida → resuelta (o abierta → resuelta). IDActor es el
+// usuario que la provocó; 0 si la hizo el sistema (resolución automática).
+type AlertaEstado struct {
+	IDAlerta       int64     `json:"id_alerta"`
+	IDPlanta       int       `json:"id_planta"`
+	TipoAlerta     string    `json:"tipo_alerta"`
+	MacAddress     string    `json:"mac_address,omitempty"`
+	Nombre         string    `json:"nombre,omitempty"`
+	Estado         string    `json:"estado"`
+	EstadoAnterior string    `json:"estado_anterior"`
+	IDActor        int       `json:"id_actor,omitempty"`
+	Fecha          time.Time `json:"fecha"`
+}
+
+func newEnvelope(tipo string, seq uint64, payload interface{}) Envelope {
+	return Envelope{
+		Type:     tipo,
+		Version:  EnvelopeVersion,
+		Seq:      seq,
+		ServerTS: time.Now().UTC(),
+		Payload:  payload,
+	}
+}
+
+// frame es un envelope ya serializado, listo para cualquier transporte
+type frame struct {
+	seq   uint64
+	epoch string
+	tipo  string
+	data  []byte
+}
+
+func (e Envelope) frame() (frame, bool) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("❌ Error serializando envelope %s: %v", e.Type, err)
+		return frame{}, false
+	}
+	return frame{seq: e.Seq, epoch: e.Epoch, tipo: e.Type, data: data}, true
+}
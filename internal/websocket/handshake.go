@@ -0,0 +1,94 @@
+package websocket
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"WEBSOCKER_EASYGROW/internal/auth"
+)
+
+// originAllowed valida el Origin contra WS_ALLOWED_ORIGINS (lista separada
+// por comas). Sin la variable se acepta cualquier origen.
+func originAllowed(r *http.Request) bool {
+	allowed := os.Getenv("WS_ALLOWED_ORIGINS")
+	origin := r.Header.Get("Origin")
+	if allowed == "" || origin == "" {
+		return true
+	}
+	for _, o := range strings.Split(allowed, ",") {
+		if strings.EqualFold(strings.TrimSpace(o), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate resuelve el id_usuario del handshake o responde con el
+// código HTTP correspondiente sin abrir el stream
+func authenticate(w http.ResponseWriter, r *http.Request) (int, bool) {
+	if !originAllowed(r) {
+		log.Printf("🚫 Origen no permitido: %s", r.Header.Get("Origin"))
+		http.Error(w, "origen no permitido", http.StatusForbidden)
+		return 0, false
+	}
+
+	userID, err := auth.VerifyToken(auth.TokenFromRequest(r))
+	switch {
+	case err == nil:
+		return userID, true
+	case errors.Is(err, auth.ErrNoSecret):
+		log.Printf("❌ Autenticación no disponible: %v", err)
+		http.Error(w, "autenticación no configurada", http.StatusServiceUnavailable)
+	default:
+		log.Printf("🚫 Handshake rechazado desde %s: %v", r.RemoteAddr, err)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="easygrow"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	}
+	return 0, false
+}
+
+// resumeToken es el último seq que el cliente recibió antes de
+// reconectarse, con el epoch de la instancia que lo asignó. Se escribe
+// "epoch:seq"; un seq solo, sin epoch, se toma como de otra instancia.
+type resumeToken struct {
+	epoch string
+	seq   uint64
+}
+
+func parseResumeToken(v string) (resumeToken, bool) {
+	epoch, seqStr, ok := strings.Cut(v, ":")
+	if !ok {
+		epoch, seqStr = "", v
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return resumeToken{}, false
+	}
+	return resumeToken{epoch: epoch, seq: seq}, true
+}
+
+// resumePoint devuelve el token de reanudación del cliente, tomado del
+// primer valor presente en los nombres indicados (cabeceras o parámetros
+// de la URL)
+func resumePoint(r *http.Request, headers []string, params []string) resumeToken {
+	var values []string
+	for _, h := range headers {
+		values = append(values, r.Header.Get(h))
+	}
+	for _, p := range params {
+		values = append(values, r.URL.Query().Get(p))
+	}
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if token, ok := parseResumeToken(v); ok {
+			return token
+		}
+	}
+	return resumeToken{}
+}
@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+const (
+	macA = "AA:BB:CC:DD:EE:01"
+	macB = "AA:BB:CC:DD:EE:02"
+)
+
+func startHub(t *testing.T) *Hub {
+	t.Helper()
+	h := NewHub()
+	go h.Run()
+	return h
+}
+
+func alerta(mac string) Message {
+	return Message{Tipo: TipoAlerta, MacAddress: mac, IDUsuario: 1, Payload: Alerta{MacAddress: mac}}
+}
+
+// publishAndWait publica y espera a que Hub.Run le asigne seq
+func publishAndWait(t *testing.T, h *Hub, msg Message) {
+	t.Helper()
+	n := h.metrics.publicados.Load()
+	h.Publish(msg)
+	waitFor(t, func() bool { return h.metrics.publicados.Load() > n })
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("tiempo de espera agotado")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func subscribe(t *testing.T, h *Hub, c *Client, sub Subscription) {
+	t.Helper()
+	params, _ := json.Marshal(sub)
+	if _, cerr := h.changeSubscription(c, Command{Cmd: "subscribe", Params: params}, true); cerr != nil {
+		t.Fatalf("subscribe %+v: %s", sub, cerr.Message)
+	}
+}
+
+// drain devuelve los envelopes que ya están en el buffer del cliente
+func drain(t *testing.T, c *Client) []Envelope {
+	t.Helper()
+	var envs []Envelope
+	for {
+		select {
+		case f := <-c.send:
+			var env Envelope
+			if err := json.Unmarshal(f.data, &env); err != nil {
+				t.Fatalf("frame inválido: %v", err)
+			}
+			envs = append(envs, env)
+		default:
+			return envs
+		}
+	}
+}
+
+func TestResumeReplaysEverySubscription(t *testing.T) {
+	h := startHub(t)
+	publishAndWait(t, h, alerta(macA)) // seq 1: lo último que recibió el cliente
+	publishAndWait(t, h, alerta(macA)) // seq 2
+	publishAndWait(t, h, alerta(macB)) // seq 3
+
+	c := newClient(h, "test", 1)
+	c.resumeFrom = resumeToken{epoch: h.epoch, seq: 1}
+	h.Register(c)
+
+	subscribe(t, h, c, Subscription{MacAddress: macA})
+	subscribe(t, h, c, Subscription{MacAddress: macB})
+	// Ya cubierta por las anteriores: no se repite nada
+	subscribe(t, h, c, Subscription{Tipo: TipoAlerta})
+
+	var seqs []uint64
+	for _, env := range drain(t, c) {
+		if env.Type == TipoSnapshot {
+			t.Fatalf("se esperaba replay, llegó un snapshot")
+		}
+		seqs = append(seqs, env.Seq)
+	}
+	if len(seqs) != 2 || seqs[0] != 2 || seqs[1] != 3 {
+		t.Fatalf("seqs reenviados = %v, se esperaba [2 3]", seqs)
+	}
+}
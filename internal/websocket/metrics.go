@@ -0,0 +1,34 @@
+package websocket
+
+import "sync/atomic"
+
+// Metrics es una foto de los contadores del Hub para /metrics
+type Metrics struct {
+	ConexionesActivas   int64 `json:"conexiones_activas"`
+	ConexionesTotales   int64 `json:"conexiones_totales"`
+	ConexionesCaducadas int64 `json:"conexiones_caducadas"`
+	ClientesLentos      int64 `json:"clientes_lentos_desconectados"`
+	MensajesPublicados  int64 `json:"mensajes_publicados"`
+	MensajesDescartados int64 `json:"mensajes_descartados"`
+}
+
+type hubMetrics struct {
+	activas     atomic.Int64
+	totales     atomic.Int64
+	caducadas   atomic.Int64
+	lentos      atomic.Int64
+	publicados  atomic.Int64
+	descartados atomic.Int64
+}
+
+// Metrics devuelve los contadores actuales; se puede llamar desde cualquier goroutine
+func (h *Hub) Metrics() Metrics {
+	return Metrics{
+		ConexionesActivas:   h.metrics.activas.Load(),
+		ConexionesTotales:   h.metrics.totales.Load(),
+		ConexionesCaducadas: h.metrics.caducadas.Load(),
+		ClientesLentos:      h.metrics.lentos.Load(),
+		MensajesPublicados:  h.metrics.publicados.Load(),
+		MensajesDescartados: h.metrics.descartados.Load(),
+	}
+}
@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const defaultReplaySize = 200
+
+// replayRing guarda los últimos envelopes de un tópico (un dispositivo)
+// para reenviarlos a clientes que se reconectan
+type replayRing struct {
+	items   []cachedMessage
+	size    int
+	owner   int
+	evicted uint64 // seq más alto que ya salió del buffer
+}
+
+func (r *replayRing) add(cm cachedMessage) {
+	if len(r.items) == r.size {
+		r.evicted = r.items[0].env.Seq
+		r.items = append(r.items[:0], r.items[1:]...)
+	}
+	r.items = append(r.items, cm)
+	r.owner = cm.msg.IDUsuario
+}
+
+// replayBuffer agrupa los anillos por MAC. Solo se usa desde Hub.Run.
+type replayBuffer struct {
+	size  int
+	rings map[string]*replayRing
+}
+
+func newReplayBuffer(size int) *replayBuffer {
+	return &replayBuffer{size: size, rings: make(map[string]*replayRing)}
+}
+
+func (b *replayBuffer) store(msg Message, env Envelope) {
+	mac := normalizeMac(msg.MacAddress)
+	if mac == "" {
+		return
+	}
+	r, ok := b.rings[mac]
+	if !ok {
+		r = &replayRing{size: b.size, items: make([]cachedMessage, 0, b.size)}
+		b.rings[mac] = r
+	}
+	r.add(cachedMessage{msg: msg, env: env})
+}
+
+// since devuelve, ordenados por seq, los envelopes posteriores a from que
+// acepta el filtro. ok=false si algún tópico del usuario que cubre inScope
+// ya perdió mensajes posteriores a from y hace falta un snapshot completo.
+func (b *replayBuffer) since(userID int, from uint64, inScope func(mac string) bool, accept func(Message) bool) (missed []Envelope, ok bool) {
+	for mac, r := range b.rings {
+		if r.owner != userID || !inScope(mac) {
+			continue
+		}
+		if r.evicted > from {
+			return nil, false
+		}
+		for _, cm := range r.items {
+			if cm.env.Seq > from && accept(cm.msg) {
+				missed = append(missed, cm.env)
+			}
+		}
+	}
+	sort.Slice(missed, func(i, j int) bool { return missed[i].Seq < missed[j].Seq })
+	return missed, true
+}
+
+// catchUp envía al cliente lo que se perdió desde from para las
+// suscripciones indicadas, salvo lo que acepte skip (si no es nil), o un
+// snapshot si el hueco es demasiado grande. Debe llamarse desde Hub.Run.
+func (h *Hub) catchUp(c *Client, subs []Subscription, from resumeToken, skip func(Message) bool) {
+	inScope := func(mac string) bool {
+		for _, sub := range subs {
+			if sub.MacAddress == "" || sub.MacAddress == mac {
+				return true
+			}
+		}
+		return false
+	}
+	accept := func(m Message) bool {
+		if skip != nil && skip(m) {
+			return false
+		}
+		for _, sub := range subs {
+			if sub.matches(m) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Otro epoch significa que el seq lo asignó otra instancia (detrás de
+	// un backplane) o un arranque anterior de esta: no se puede comparar
+	missed, ok := []Envelope(nil), false
+	if from.epoch == h.epoch && from.seq <= h.seq {
+		missed, ok = h.replay.since(c.userID, from.seq, inScope, accept)
+	}
+
+	if !ok {
+		log.Printf("⚠️ No se puede reanudar desde %s:%d para usuario %d, enviando snapshot", from.epoch, from.seq, c.userID)
+		h.sendSnapshot(c, accept)
+		return
+	}
+	for _, env := range missed {
+		f, ok := env.frame()
+		if ok && !c.enqueue(f, h.overflow) {
+			h.removeClient(c)
+			return
+		}
+	}
+}
+
+// resume hace el catch-up desde c.resumeFrom de suscripciones que todavía
+// no se agregaron al cliente, sin repetir lo que ya cubren las que tiene.
+// El punto de reanudación vale para toda la conexión: cada subscribe de la
+// reconexión recibe lo que se perdió de lo suyo. Debe llamarse desde Hub.Run.
+func (h *Hub) resume(c *Client, subs []Subscription) {
+	h.catchUp(c, subs, c.resumeFrom, c.wants)
+}
+
+// newEpoch genera un identificador al azar para este arranque del Hub
+func newEpoch() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		// Sin azar disponible, la hora de arranque alcanza para distinguir reinicios
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// sendSnapshot envía los últimos valores que acepta el filtro, si hay alguno.
+// Debe llamarse desde Hub.Run.
+func (h *Hub) sendSnapshot(c *Client, accept func(Message) bool) {
+	snap := h.cache.snapshot(c.userID, accept)
+	if snap.empty() {
+		return
+	}
+	if f, ok := newEnvelope(TipoSnapshot, 0, snap).frame(); ok && !c.enqueue(f, h.overflow) {
+		h.removeClient(c)
+	}
+}
@@ -0,0 +1,96 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var macPattern = regexp.MustCompile(`^([0-9A-Fa-f]{2}[:-]){5}[0-9A-Fa-f]{2}$`)
+
+// fieldSchema describe un parámetro de comando, al estilo de JSON Schema
+type fieldSchema struct {
+	Type      string // "string", "integer", "number" o "boolean"
+	Required  bool
+	Enum      []string
+	Pattern   *regexp.Regexp
+	MaxLength int
+}
+
+// paramsSchema valida el objeto params de un comando. No se aceptan
+// propiedades que no estén declaradas.
+type paramsSchema map[string]fieldSchema
+
+// validate devuelve un error por cada parámetro que no cumple el esquema
+func (s paramsSchema) validate(raw json.RawMessage) []string {
+	params := map[string]interface{}{}
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return []string{"params debe ser un objeto JSON"}
+		}
+	}
+
+	var problems []string
+	for name := range params {
+		if _, ok := s[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: propiedad no permitida", name))
+		}
+	}
+
+	for name, field := range s {
+		value, ok := params[name]
+		if !ok || value == nil {
+			if field.Required {
+				problems = append(problems, fmt.Sprintf("%s: requerido", name))
+			}
+			continue
+		}
+		if p := field.check(value); p != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, p))
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+func (f fieldSchema) check(value interface{}) string {
+	switch f.Type {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return "debe ser string"
+		}
+		if f.MaxLength > 0 && len(str) > f.MaxLength {
+			return fmt.Sprintf("longitud máxima %d", f.MaxLength)
+		}
+		if f.Pattern != nil && !f.Pattern.MatchString(str) {
+			return "formato inválido"
+		}
+		if len(f.Enum) > 0 {
+			for _, e := range f.Enum {
+				if str == e {
+					return ""
+				}
+			}
+			return "debe ser uno de: " + strings.Join(f.Enum, ", ")
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return "debe ser entero"
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return "debe ser número"
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return "debe ser booleano"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package websocket
+
+// cacheKey identifica una lectura por dispositivo y sensor
+type cacheKey struct {
+	mac    string
+	nombre string
+}
+
+type cachedMessage struct {
+	msg Message
+	env Envelope
+}
+
+// lastValueCache guarda el último valor de cada sensor y el último estado
+// de bomba de cada dispositivo. Solo se usa desde Hub.Run.
+type lastValueCache struct {
+	lecturas map[cacheKey]cachedMessage
+	bombas   map[string]cachedMessage
+}
+
+func newLastValueCache() *lastValueCache {
+	return &lastValueCache{
+		lecturas: make(map[cacheKey]cachedMessage),
+		bombas:   make(map[string]cachedMessage),
+	}
+}
+
+func (c *lastValueCache) store(msg Message, env Envelope) {
+	mac := normalizeMac(msg.MacAddress)
+	switch msg.Tipo {
+	case TipoSensor:
+		c.lecturas[cacheKey{mac: mac, nombre: msg.Nombre}] = cachedMessage{msg: msg, env: env}
+	case TipoBomba:
+		c.bombas[mac] = cachedMessage{msg: msg, env: env}
+	}
+}
+
+// Snapshot es el payload de un frame de tipo "snapshot": los envelopes
+// más recientes que el cliente puede ver
+type Snapshot struct {
+	Lecturas []Envelope `json:"lecturas"`
+	Bombas   []Envelope `json:"bombas"`
+}
+
+func (s Snapshot) empty() bool {
+	return len(s.Lecturas) == 0 && len(s.Bombas) == 0
+}
+
+// snapshot arma lo que el usuario puede ver y acepta el filtro
+func (c *lastValueCache) snapshot(userID int, accept func(Message) bool) Snapshot {
+	snap := Snapshot{Lecturas: []Envelope{}, Bombas: []Envelope{}}
+	for _, cm := range c.lecturas {
+		if cm.msg.IDUsuario == userID && accept(cm.msg) {
+			snap.Lecturas = append(snap.Lecturas, cm.env)
+		}
+	}
+	for _, cm := range c.bombas {
+		if cm.msg.IDUsuario == userID && accept(cm.msg) {
+			snap.Bombas = append(snap.Bombas, cm.env)
+		}
+	}
+	return snap
+}
+
+// snapshotFor consulta el cache desde fuera de Hub.Run. Sin filtro usa las
+// suscripciones actuales del cliente.
+func (h *Hub) snapshotFor(c *Client, filter *Subscription) Snapshot {
+	snap := Snapshot{Lecturas: []Envelope{}, Bombas: []Envelope{}}
+	h.do(c, func() {
+		accept := c.wants
+		if filter != nil {
+			accept = filter.matches
+		}
+		snap = h.cache.snapshot(c.userID, accept)
+	}, nil)
+	return snap
+}
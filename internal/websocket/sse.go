@@ -0,0 +1,128 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// HandleSSE sirve el mismo flujo del Hub como Server-Sent Events para
+// clientes que no pueden hacer upgrade a WebSocket. Las suscripciones van
+// en la URL (mac_address se puede repetir) y la reanudación usa la
+// cabecera Last-Event-ID o el parámetro last_event_id:
+//
+//	GET /events?token=...&mac_address=AA:BB:CC:DD:EE:FF&tipo=sensor_data
+func HandleSSE(hub *Hub, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	subs, problems := subscriptionsFromQuery(r)
+	if len(problems) > 0 {
+		http.Error(w, fmt.Sprintf("suscripción inválida: %v", problems), http.StatusBadRequest)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil {
+		log.Printf("❌ SSE no soportado por la conexión de %s: %v", r.RemoteAddr, err)
+		return
+	}
+	log.Printf("🔐 Usuario %d conectado por SSE desde %s", userID, r.RemoteAddr)
+
+	client := newClient(hub, r.RemoteAddr, userID)
+	client.resumeFrom = resumePoint(r, []string{"Last-Event-ID"}, []string{"last_event_id"})
+	hub.Register(client)
+	hub.subscribeAll(client, subs)
+
+	ka := hub.keepalive
+	ticker := time.NewTicker(ka.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Printf("🔌 Conexión SSE cerrada: %s", client.remote)
+			hub.Unregister(client)
+			return
+		case f, ok := <-client.send:
+			if !ok {
+				return
+			}
+			rc.SetWriteDeadline(time.Now().Add(ka.writeTimeout))
+			err := writeSSEFrame(w, f)
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				log.Printf("❌ Error enviando evento SSE: %v", err)
+				client.stop()
+				return
+			}
+		case <-ticker.C:
+			// Comentario SSE para que proxies y clientes no cierren por inactividad
+			rc.SetWriteDeadline(time.Now().Add(ka.writeTimeout))
+			_, err := fmt.Fprint(w, ": ping\n\n")
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				log.Printf("🔌 Ping SSE fallido a %s, cerrando conexión: %v", client.remote, err)
+				hub.metrics.caducadas.Add(1)
+				client.stop()
+				return
+			}
+		}
+	}
+}
+
+// Solo los frames del flujo llevan id, así Last-Event-ID siempre es un
+// token de reanudación "epoch:seq"
+func writeSSEFrame(w http.ResponseWriter, f frame) error {
+	if f.seq > 0 {
+		if _, err := fmt.Fprintf(w, "id: %s:%d\n", f.epoch, f.seq); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", f.tipo, f.data)
+	return err
+}
+
+// subscriptionsFromQuery arma una suscripción por cada mac_address de la
+// URL (o una sola si no hay), validadas con el mismo esquema que el comando subscribe
+func subscriptionsFromQuery(r *http.Request) ([]Subscription, []string) {
+	q := r.URL.Query()
+	macs := q["mac_address"]
+	if len(macs) == 0 {
+		macs = []string{""}
+	}
+
+	var subs []Subscription
+	for _, mac := range macs {
+		sub := Subscription{MacAddress: mac, Nombre: q.Get("nombre"), Tipo: q.Get("tipo")}
+		params, _ := json.Marshal(sub)
+		if problems := subscriptionSchema.validate(params); len(problems) > 0 {
+			return nil, problems
+		}
+		sub = sub.normalize()
+		if sub.empty() {
+			return nil, []string{"se necesita mac_address, nombre o tipo"}
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
@@ -0,0 +1,38 @@
+package websocket
+
+import (
+	"strings"
+)
+
+// Subscription filtra mensajes por MAC, nombre de sensor y/o tipo.
+// Un campo vacío acepta cualquier valor.
+type Subscription struct {
+	MacAddress string `json:"mac_address,omitempty"`
+	Nombre     string `json:"nombre,omitempty"`
+	Tipo       string `json:"tipo,omitempty"`
+}
+
+func (s Subscription) normalize() Subscription {
+	return Subscription{
+		MacAddress: normalizeMac(s.MacAddress),
+		Nombre:     strings.TrimSpace(s.Nombre),
+		Tipo:       strings.TrimSpace(s.Tipo),
+	}
+}
+
+func (s Subscription) empty() bool {
+	return s.MacAddress == "" && s.Nombre == "" && s.Tipo == ""
+}
+
+func (s Subscription) matches(m Message) bool {
+	if s.MacAddress != "" && !strings.EqualFold(s.MacAddress, m.MacAddress) {
+		return false
+	}
+	if s.Nombre != "" && s.Nombre != m.Nombre {
+		return false
+	}
+	if s.Tipo != "" && s.Tipo != m.Tipo {
+		return false
+	}
+	return true
+}
@@ -2,79 +2,185 @@ package websocket
 
 import (
 	"log"
-	"net/http"
+	"sync"
 
-	"github.com/gorilla/websocket"
+	"WEBSOCKER_EASYGROW/utils"
 )
 
 type Hub struct {
-	clients    map[*websocket.Conn]bool
-	broadcast  chan []byte
-	register   chan *websocket.Conn
-	unregister chan *websocket.Conn
+	clients    map[*Client]bool
+	broadcast  chan Message
+	register   chan *Client
+	unregister chan *Client
+	ops        chan clientOp
+
+	sendBuffer int
+	overflow   OverflowPolicy
+	keepalive  keepalive
+	metrics    hubMetrics
+
+	actionsMu sync.RWMutex
+	actions   Actions
+
+	backplaneMu sync.RWMutex
+	backplane   Backplane
+
+	// Último número de secuencia asignado; solo lo toca Hub.Run
+	seq uint64
+	// Identifica este arranque del Hub en los envelopes y los tokens de reanudación
+	epoch string
+	// Últimos valores conocidos para el snapshot inicial; solo los toca Hub.Run
+	cache *lastValueCache
+	// Mensajes recientes por dispositivo para reanudar; solo los toca Hub.Run
+	replay *replayBuffer
 }
 
 func NewHub() *Hub {
 	return &Hub{
-		clients:    make(map[*websocket.Conn]bool),
-		broadcast:  make(chan []byte),
-		register:   make(chan *websocket.Conn),
-		unregister: make(chan *websocket.Conn),
+		clients:    make(map[*Client]bool),
+		broadcast:  make(chan Message, defaultSendBuffer),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		ops:        make(chan clientOp),
+		sendBuffer: utils.EnvPositiveInt("WS_SEND_BUFFER", defaultSendBuffer),
+		overflow:   overflowPolicyFromEnv(),
+		keepalive:  keepaliveFromEnv(),
+		epoch:      newEpoch(),
+		cache:      newLastValueCache(),
+		replay:     newReplayBuffer(utils.EnvPositiveInt("WS_REPLAY_BUFFER", defaultReplaySize)),
 	}
 }
 
 func (h *Hub) Run() {
+	log.Printf("🔧 Hub WebSocket: epoch %s, buffer por cliente %d, política de desbordamiento %s", h.epoch, h.sendBuffer, h.overflow)
+	log.Printf("🔧 Keepalive: ping cada %s, pong timeout %s, write timeout %s, mensaje máx %d bytes",
+		h.keepalive.pingInterval, h.keepalive.pongTimeout, h.keepalive.writeTimeout, h.keepalive.maxMessageSize)
+
 	for {
 		select {
-		case conn := <-h.register:
-			h.clients[conn] = true
-		case conn := <-h.unregister:
-			if _, ok := h.clients[conn]; ok {
-				delete(h.clients, conn)
-				conn.Close()
-			}
+		case client := <-h.register:
+			h.clients[client] = true
+			h.metrics.activas.Add(1)
+			h.metrics.totales.Add(1)
+		case client := <-h.unregister:
+			h.removeClient(client)
+		case op := <-h.ops:
+			h.applyOp(op)
 		case message := <-h.broadcast:
+			h.seq++
+			env := newEnvelope(message.Tipo, h.seq, message.Payload)
+			env.Epoch = h.epoch
+			f, ok := env.frame()
+			if !ok {
+				continue
+			}
+			h.metrics.publicados.Add(1)
+			h.cache.store(message, env)
+			h.replay.store(message, env)
+			// Nunca se escribe directamente en la conexión: cada cliente
+			// tiene su propia goroutine de escritura (WebSocket o SSE), así
+			// un cliente lento no frena al resto
 			for client := range h.clients {
-				err := client.WriteMessage(websocket.TextMessage, message)
-				if err != nil {
-					log.Printf("❌ Error enviando mensaje: %v", err)
-					client.Close()
-					delete(h.clients, client)
+				if !client.wants(message) {
+					continue
+				}
+				if !client.enqueue(f, h.overflow) {
+					h.removeClient(client)
 				}
 			}
 		}
 	}
 }
 
-func (h *Hub) Broadcast(msg []byte) {
-	h.broadcast <- msg
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.send)
+		h.metrics.activas.Add(-1)
+	}
+}
+
+// clientOp es un cambio de estado y/o un frame para un único cliente que
+// se aplica dentro de Hub.Run, dueño del estado de los clientes
+type clientOp struct {
+	client *Client
+	apply  func()
+	out    *frame
+	done   chan struct{}
 }
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+// do espera a que Hub.Run aplique la operación; si el cliente ya no está
+// registrado no se aplica nada
+func (h *Hub) do(client *Client, apply func(), out *frame) {
+	done := make(chan struct{})
+	h.ops <- clientOp{client: client, apply: apply, out: out, done: done}
+	<-done
 }
 
-func HandleConnections(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	ws, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("❌ Error al hacer upgrade a WebSocket: %v", err)
+func (h *Hub) applyOp(op clientOp) {
+	defer close(op.done)
+	if _, ok := h.clients[op.client]; !ok {
 		return
 	}
-	hub.register <- ws
+	if op.apply != nil {
+		op.apply()
+	}
+	if op.out != nil && !op.client.enqueue(*op.out, h.overflow) {
+		h.removeClient(op.client)
+	}
+}
 
-	defer func() {
-		hub.unregister <- ws
-	}()
+// SetActions registra quién ejecuta ack_alert y pump_action
+func (h *Hub) SetActions(actions Actions) {
+	h.actionsMu.Lock()
+	h.actions = actions
+	h.actionsMu.Unlock()
+}
 
-	for {
-		_, msg, err := ws.ReadMessage()
-		if err != nil {
-			log.Printf("🔌 Conexión cerrada: %v", err)
-			break
-		}
-		log.Printf("📨 Mensaje recibido: %s", msg)
+func (h *Hub) getActions() Actions {
+	h.actionsMu.RLock()
+	defer h.actionsMu.RUnlock()
+	return h.actions
+}
 
-		// reenviar el mensaje a todos los clientes conectados
-		hub.Broadcast(msg)
+// Publish entrega el mensaje solo a los clientes con una suscripción que
+// coincida, en esta y (si hay backplane) en las demás instancias. El seq
+// lo asigna cada instancia al entregar, por lo que es local a cada una;
+// por eso va acompañado del epoch de la instancia.
+func (h *Hub) Publish(msg Message) {
+	if b := h.getBackplane(); b != nil {
+		err := b.Publish(msg)
+		if err == nil {
+			return
+		}
+		logBackplaneFallback(err)
 	}
+	h.deliver(msg)
+}
+
+// Register agrega un cliente ya autenticado, sea cual sea su transporte
+func (h *Hub) Register(client *Client) {
+	h.register <- client
+}
+
+// Unregister saca al cliente del Hub; es seguro llamarlo más de una vez
+func (h *Hub) Unregister(client *Client) {
+	h.unregister <- client
+}
+
+// subscribeAll agrega varias suscripciones de una vez y envía lo que el
+// cliente se perdió (si trae resumeFrom) o el snapshot correspondiente
+func (h *Hub) subscribeAll(c *Client, subs []Subscription) {
+	h.do(c, func() {
+		resuming := c.resumeFrom.seq > 0
+		if resuming {
+			h.resume(c, subs)
+		}
+		for _, sub := range subs {
+			c.subs[sub] = true
+		}
+		if !resuming {
+			h.sendSnapshot(c, c.wants)
+		}
+	}, nil)
 }
@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	// El origen ya se validó en authenticate antes del upgrade
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsConn es el transporte WebSocket de un Client: lee comandos y escribe
+// lo que el Hub deja en el buffer del cliente
+type wsConn struct {
+	client *Client
+	conn   *websocket.Conn
+}
+
+func HandleConnections(hub *Hub, w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("❌ Error al hacer upgrade a WebSocket: %v", err)
+		return
+	}
+	log.Printf("🔐 Usuario %d conectado por WebSocket desde %s", userID, r.RemoteAddr)
+
+	client := newClient(hub, r.RemoteAddr, userID)
+	// Un cliente que se reconecta indica el último seq que recibió, como "epoch:seq"
+	client.resumeFrom = resumePoint(r, nil, []string{"resume_from"})
+
+	c := &wsConn{client: client, conn: ws}
+	hub.Register(client)
+	go c.writePump()
+
+	defer func() {
+		hub.Unregister(client)
+		ws.Close()
+	}()
+
+	c.readPump()
+}
+
+// writePump envía a la conexión todo lo que llega al canal send y un ping
+// periódico. Termina cuando el Hub cierra el canal o falla una escritura.
+func (c *wsConn) writePump() {
+	ka := c.client.hub.keepalive
+	ticker := time.NewTicker(ka.pingInterval)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case f, ok := <-c.client.send:
+			c.conn.SetWriteDeadline(time.Now().Add(ka.writeTimeout))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, f.data); err != nil {
+				log.Printf("❌ Error enviando mensaje: %v", err)
+				c.client.stop()
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(ka.writeTimeout))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Printf("🔌 Ping fallido a %s, cerrando conexión: %v", c.client.remote, err)
+				c.client.hub.metrics.caducadas.Add(1)
+				c.client.stop()
+				return
+			}
+		}
+	}
+}
+
+// readPump lee comandos hasta que la conexión se cierra o deja de
+// responder a los pings dentro de WS_PONG_TIMEOUT
+func (c *wsConn) readPump() {
+	ka := c.client.hub.keepalive
+	c.conn.SetReadLimit(ka.maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(ka.pongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(ka.pongTimeout))
+	})
+
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("💀 Conexión sin respuesta de %s, cerrando", c.client.remote)
+				c.client.hub.metrics.caducadas.Add(1)
+			} else {
+				log.Printf("🔌 Conexión cerrada: %v", err)
+			}
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(ka.pongTimeout))
+		log.Printf("📨 Mensaje recibido: %s", msg)
+
+		// Los frames entrantes son comandos; nunca se reenvían a otros clientes
+		c.client.hub.handleCommand(c.client, msg)
+	}
+}
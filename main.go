@@ -1,11 +1,16 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 
 	"WEBSOCKER_EASYGROW/internal/amqp"
+	"WEBSOCKER_EASYGROW/internal/auth"
 	"WEBSOCKER_EASYGROW/internal/websocket"
 	"WEBSOCKER_EASYGROW/utils"
 )
@@ -14,35 +19,158 @@ func main() {
 	// Cargar variables de entorno
 	utils.LoadEnv()
 
+	// Solo comparar la topología AMQP con el broker y salir
+	if os.Getenv("AMQP_TOPOLOGY_DRY_RUN") == "true" {
+		os.Exit(amqp.TopologyDryRun())
+	}
+
 	// Crear el hub de WebSocket
 	hub := websocket.NewHub()
 	go hub.Run()
 
+	// Canales de notificación (ALERT_CHANNELS, ALERT_CHANNELS_INFO); para
+	// agregar uno nuevo, alerts.RegisterFactory antes de esta línea
+	notifiers := amqp.NotifiersFromEnv()
+
 	// Iniciar el consumidor de múltiples colas en una goroutine
-	go amqp.ConsumeFromQueues(hub)
+	go amqp.ConsumeFromQueues(hub, notifiers)
 
 	// Configurar el endpoint de WebSocket
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		websocket.HandleConnections(hub, w, r)
 	})
 
-	// Configurar endpoint de salud para verificar que el servicio esté corriendo
+	// Mismo flujo por Server-Sent Events para clientes sin WebSocket
+	http.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		websocket.HandleSSE(hub, w, r)
+	})
+
+	// Contadores del hub (conexiones activas, caducadas, mensajes descartados...)
+	// y del consumidor (duplicados descartados)
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			websocket.Metrics
+			amqp.IngestMetrics
+		}{hub.Metrics(), amqp.Metrics()})
+	})
+
+	// Olvidar los ids de sensor/planta resueltos (?mac_address=..., sin MAC vacía todo).
+	// Solo afecta a esta instancia; para todas, publicar en el exchange de control.
+	http.HandleFunc("/admin/cache/invalidate", adminOnly(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		n := amqp.InvalidateIDCache(r.URL.Query().Get("mac_address"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"invalidadas": n})
+	}))
+
+	// Volver a leer umbrales_alerta sin esperar a THRESHOLDS_RELOAD (solo esta instancia)
+	http.HandleFunc("/admin/thresholds/reload", adminOnly(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := amqp.ReloadThresholds(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"recargado": true})
+	}))
+
+	// Mensajes que no pasaron la validación (?cola=...&limit=...&incluir_reenviados=true)
+	http.HandleFunc("/admin/quarantine", adminOnly(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		mensajes, err := amqp.ListQuarantine(q.Get("cola"), limit, q.Get("incluir_reenviados") == "true")
+		if err != nil {
+			http.Error(w, err.Error(), quarantineStatus(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(mensajes)
+	}))
+
+	// Volver a publicar un mensaje de cuarentena en su cola (?id=...)
+	http.HandleFunc("/admin/quarantine/resubmit", adminOnly(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "id inválido", http.StatusBadRequest)
+			return
+		}
+		if err := amqp.ResubmitQuarantine(id); err != nil {
+			http.Error(w, err.Error(), quarantineStatus(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{"reenviado": id})
+	}))
+
+	// Configurar endpoint de salud; responde 503 mientras RabbitMQ no esté conectado
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{
-			"status": "ok",
-			"service": "EasyGrow WebSocket Consumer",
-			"version": "2.0",
-			"queues": ["datos_sensores", "eventos_bomba"]
-		}`))
+		rabbit := amqp.Status()
+		estado := "ok"
+		code := http.StatusOK
+		if rabbit.Estado != amqp.EstadoConectado {
+			estado = "degradado"
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   estado,
+			"service":  "EasyGrow WebSocket Consumer",
+			"version":  "2.0",
+			"queues":   amqp.Queues(),
+			"rabbitmq": rabbit,
+		})
 	})
 
 	log.Println("🚀 Servidor EasyGrow WebSocket iniciado en puerto :8080")
-	log.Println("📊 Consumiendo de cola: datos_sensores")
-	log.Println("🚰 Consumiendo de cola: eventos_bomba")
+	for _, queue := range amqp.Queues() {
+		log.Printf("📊 Consumiendo de cola: %s", queue)
+	}
 	log.Println("🌐 WebSocket endpoint: ws://localhost:8080/ws")
+	log.Println("📡 SSE endpoint: http://localhost:8080/events")
 	log.Println("=" + strings.Repeat("=", 50))
 	log.Println("=" + strings.Repeat("=", 50))
 
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
+
+func quarantineStatus(err error) int {
+	switch {
+	case errors.Is(err, amqp.ErrQuarantineNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, amqp.ErrQuarantineUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// adminOnly protege los endpoints de administración con ADMIN_TOKEN
+func adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := auth.VerifyAdmin(r); err != nil {
+			code := http.StatusUnauthorized
+			if errors.Is(err, auth.ErrNoAdminToken) {
+				code = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		next(w, r)
+	}
+}
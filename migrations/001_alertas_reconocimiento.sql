@@ -0,0 +1,5 @@
+-- Reconocimiento de alertas desde el WebSocket (comando ack_alert)
+ALTER TABLE alertas
+    ADD COLUMN reconocida TINYINT(1) NOT NULL DEFAULT 0,
+    ADD COLUMN fecha_reconocida DATETIME NULL,
+    ADD COLUMN reconocida_por INT NULL;
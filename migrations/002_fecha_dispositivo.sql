@@ -0,0 +1,9 @@
+-- Hora de la lectura/evento según el dispositivo (en UTC) y marca de reloj desfasado.
+-- Si el reloj del dispositivo está fuera de rango se guarda la hora de recepción.
+ALTER TABLE lectura_datos
+    ADD COLUMN fecha_lectura DATETIME(3) NULL,
+    ADD COLUMN desfase_reloj TINYINT(1) NOT NULL DEFAULT 0;
+
+ALTER TABLE eventos_bomba
+    ADD COLUMN fecha_evento DATETIME(3) NULL,
+    ADD COLUMN desfase_reloj TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,14 @@
+-- Mensajes de RabbitMQ que no pasaron la validación, para revisarlos y re-enviarlos
+CREATE TABLE IF NOT EXISTS cuarentena_mensajes (
+    id_cuarentena BIGINT AUTO_INCREMENT PRIMARY KEY,
+    cola VARCHAR(100) NOT NULL,
+    routing_key VARCHAR(255) NULL,
+    motivo VARCHAR(50) NOT NULL,
+    detalle TEXT NULL,
+    cuerpo MEDIUMBLOB NOT NULL,
+    message_id VARCHAR(255) NULL,
+    fecha_recibido DATETIME(3) NOT NULL,
+    reenviado TINYINT(1) NOT NULL DEFAULT 0,
+    fecha_reenvio DATETIME(3) NULL,
+    INDEX idx_cuarentena_cola (cola, reenviado, id_cuarentena)
+);
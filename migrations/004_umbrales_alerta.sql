@@ -0,0 +1,24 @@
+-- Umbrales de alerta por sensor, por planta o por especie. Se aplica el más
+-- específico (id_sensor > id_planta > especie); sin fila se usan los límites
+-- por defecto del consumidor. Un límite NULL no se evalúa.
+-- tipo_sensor: temperatura, humedad, luminosidad, nivel_agua, lluvia,
+-- vibracion o humedad_suelo (obligatorio para filas por planta o especie).
+CREATE TABLE IF NOT EXISTS umbrales_alerta (
+    id_umbral INT AUTO_INCREMENT PRIMARY KEY,
+    id_sensor INT NULL,
+    id_planta INT NULL,
+    especie VARCHAR(100) NULL,
+    tipo_sensor VARCHAR(30) NULL,
+    advertencia_min DOUBLE NULL,
+    advertencia_max DOUBLE NULL,
+    critico_min DOUBLE NULL,
+    critico_max DOUBLE NULL,
+    activo TINYINT(1) NOT NULL DEFAULT 1,
+    fecha_actualizacion TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_umbrales_sensor (id_sensor),
+    INDEX idx_umbrales_planta (id_planta, tipo_sensor),
+    INDEX idx_umbrales_especie (especie, tipo_sensor)
+);
+
+-- Los umbrales por especie se cruzan con la especie de cada planta
+ALTER TABLE planta ADD COLUMN especie VARCHAR(100) NULL;
@@ -0,0 +1,9 @@
+-- Cooldown de alertas: una alerta por dispositivo, sensor y tipo mientras
+-- sigan llegando lecturas críticas; las repeticiones se cuentan sobre ella
+ALTER TABLE alertas
+    ADD COLUMN mac_address VARCHAR(17) NULL,
+    ADD COLUMN nombre_sensor VARCHAR(100) NULL,
+    ADD COLUMN repeticiones INT NOT NULL DEFAULT 1,
+    ADD COLUMN fecha_ultima_repeticion DATETIME NULL,
+    ADD COLUMN fecha_ultimo_recordatorio DATETIME NULL,
+    ADD INDEX idx_alertas_cooldown (mac_address, nombre_sensor, tipo_alerta, fecha_ultima_repeticion);
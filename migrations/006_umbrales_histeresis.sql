@@ -0,0 +1,7 @@
+-- Histéresis por umbral; NULL usa la regla por defecto del tipo de sensor.
+-- muestras / duracion_seg: cuánto tiene que sostenerse el valor crítico para alertar
+-- margen_recuperacion: cuánto tiene que volver desde el límite crítico para superarla
+ALTER TABLE umbrales_alerta
+    ADD COLUMN muestras INT NULL,
+    ADD COLUMN duracion_seg INT NULL,
+    ADD COLUMN margen_recuperacion DOUBLE NULL;
@@ -0,0 +1,9 @@
+-- Ciclo de vida de las alertas: abierta → reconocida → resuelta
+-- (reconocida/fecha_reconocida/reconocida_por vienen de 001)
+ALTER TABLE alertas
+    ADD COLUMN estado VARCHAR(20) NOT NULL DEFAULT 'abierta',
+    ADD COLUMN fecha_resuelta DATETIME NULL,
+    ADD COLUMN resuelta_por INT NULL, -- NULL: resuelta automáticamente por el consumidor
+    ADD INDEX idx_alertas_estado (mac_address, nombre_sensor, tipo_alerta, estado);
+
+UPDATE alertas SET estado = 'reconocida' WHERE reconocida = 1;
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"log"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,3 +15,29 @@ func LoadEnv() {
 		log.Fatalf("❌ Error cargando .env: %v", err)
 	}
 }
+
+// EnvPositiveInt lee un entero positivo de una variable de entorno; si
+// falta o no sirve devuelve def
+func EnvPositiveInt(name string, def int) int {
+	if v := os.Getenv(name); v != "" {
+		n, err := strconv.Atoi(v)
+		if err == nil && n > 0 {
+			return n
+		}
+		log.Printf("⚠️ %s inválido (%q), usando %d", name, v, def)
+	}
+	return def
+}
+
+// EnvDuration lee una duración positiva (ej. "30s", "2m") de una variable
+// de entorno; si falta o no sirve devuelve def
+func EnvDuration(name string, def time.Duration) time.Duration {
+	if v := os.Getenv(name); v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil && d > 0 {
+			return d
+		}
+		log.Printf("⚠️ %s inválido (%q), usando %s", name, v, def)
+	}
+	return def
+}